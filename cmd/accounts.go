@@ -1,14 +1,15 @@
 package cmd
 
 import (
-	"fmt"
 	"sort"
 
 	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/reporting"
 	"github.com/spf13/cobra"
 )
 
 func init() {
+	accountsCmd.PersistentFlags().IntVarP(&depth, "depth", "d", 0, "show accounts only to this depth")
 	rootCmd.AddCommand(accountsCmd)
 }
 
@@ -17,18 +18,16 @@ var accountsCmd = &cobra.Command{
 	Aliases:      []string{"acc", "a"},
 	Short:        "List all accounts",
 	SilenceUsage: true,
-	Run: func(_ *cobra.Command, _ []string) {
+	RunE: func(_ *cobra.Command, _ []string) error {
 		aj := newAccountsJournal()
 		th := dateCheckedTransactionHandler(aj.transactionHandler)
 		if err := parse(th, nil); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 		if err := aj.prepare(); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
-		aj.report()
+		return render(reporting.AccountsReport{Accounts: aj.accounts})
 	},
 }
 
@@ -56,30 +55,33 @@ func (aj *accountsJournal) prepare() error {
 		aj.accounts = append(aj.accounts, a)
 	}
 
-	if len(filters) == 0 {
-		sort.Strings(aj.accounts)
-		return nil
+	if len(filters) > 0 {
+		filtered := make([]string, 0)
+	accountsLoop:
+		for _, a := range aj.accounts {
+			for _, f := range filters {
+				if f.MatchesString(a) {
+					filtered = append(filtered, a)
+					continue accountsLoop
+				}
+			}
+		}
+		aj.accounts = filtered
 	}
 
-	filtered := make([]string, 0)
-accountsLoop:
-	for _, a := range aj.accounts {
-		for _, f := range filters {
-			if f.MatchesString(a) {
-				filtered = append(filtered, a)
-				continue accountsLoop
-			}
+	// Clip after filtering so that filters can match the full account paths
+	if depth > 0 {
+		clipped := make(map[string]bool, len(aj.accounts))
+		for _, a := range aj.accounts {
+			clipped[journal.ClipPath(a, depth)] = true
+		}
+		aj.accounts = aj.accounts[:0]
+		for a := range clipped {
+			aj.accounts = append(aj.accounts, a)
 		}
 	}
 
-	sort.Strings(filtered)
-	aj.accounts = filtered
+	sort.Strings(aj.accounts)
 
 	return nil
 }
-
-func (aj *accountsJournal) report() {
-	for _, a := range aj.accounts {
-		fmt.Println(a)
-	}
-}
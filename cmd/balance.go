@@ -1,9 +1,10 @@
 package cmd
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/reporting"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +13,8 @@ var (
 	collapseOnlyChildren bool
 	showZero             bool
 	showBudget           bool
+	// flag to limit how many levels of accounts are shown
+	depth int
 )
 
 var balanceCmd = &cobra.Command{
@@ -19,21 +22,23 @@ var balanceCmd = &cobra.Command{
 	Aliases:      []string{"bal", "b"},
 	Short:        "Shows accounts and their balances",
 	SilenceUsage: true,
-	Run: func(_ *cobra.Command, _ []string) {
+	RunE: func(_ *cobra.Command, _ []string) error {
 		bp := newBalanceProcessor()
 		// TODO: swap periodic transaction handler to bp one
 		if err := parse(bp.transactionHandler, bp.journal.AddPeriodicTransaction); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 
-		prepareBalance(bp.journal)
-		report(*bp.journal.Root, flattenTree, collapseOnlyChildren)
-
 		if showBudget {
-			fmt.Println("would print budget here")
-			// report(*bp.journal.BudgetRoot, flattenTree, collapseOnlyChildren)
+			report, err := bp.budgetPerformance()
+			if err != nil {
+				return err
+			}
+			return render(report)
 		}
+
+		prepareBalance(bp.journal)
+		return render(reporting.NewBalanceReport(*bp.journal.Root, flattenTree, collapseOnlyChildren))
 	},
 }
 
@@ -41,11 +46,14 @@ func init() {
 	balanceCmd.Flags().BoolVarP(&flattenTree, "flatten", "F", false, "show accounts as a flat list")
 	balanceCmd.Flags().BoolVarP(&showZero, "show-zero", "Z", false, "show accounts with zero amount")
 	balanceCmd.Flags().BoolVarP(&collapseOnlyChildren, "collapse", "C", false, "collapse single child accounts into a list")
+	balanceCmd.Flags().BoolVar(&showBudget, "budget", false, "compare each account with what periodic transactions expected, period by period")
+	balanceCmd.PersistentFlags().IntVarP(&depth, "depth", "d", 0, "show accounts only to this depth, rolling up the amounts of deeper accounts")
 	rootCmd.AddCommand(balanceCmd)
 }
 
 type balanceProcessor struct {
-	journal journal.Journal
+	journal  journal.Journal
+	postings []*journal.Posting // the matched postings, kept instead of adding them to the journal when using --budget
 }
 
 func newBalanceProcessor() balanceProcessor {
@@ -64,6 +72,12 @@ func (bp *balanceProcessor) transactionHandler(t *journal.Transaction, location
 		return nil
 	}
 
+	// Postings are added to each period's accounts when comparing against the budget
+	if showBudget {
+		bp.postings = append(bp.postings, postings...)
+		return nil
+	}
+
 	if matchedTransaction || len(postings) > 0 {
 		bp.journal.AddTransaction(t, location)
 	}
@@ -79,7 +93,90 @@ func (bp *balanceProcessor) transactionHandler(t *journal.Transaction, location
 
 // Prepare prepares the Journal for reporting
 func prepareBalance(j journal.Journal) {
+	if depth > 0 {
+		j.PruneAccounts(depth)
+	}
 	if !showZero {
-		j.Root.RemoveEmptyChildren()
+		j.RemoveEmptyAccounts()
+	}
+}
+
+// budgetPerformance compares the postings with the periodic transactions in each period.
+// Periods are as long as the shortest interval of the periodic transactions
+// and cover --begin to --end (or the postings if they are not given).
+func (bp *balanceProcessor) budgetPerformance() (reporting.BudgetPerformanceReport, error) {
+	report := reporting.BudgetPerformanceReport{}
+
+	periodic := make([]*journal.PeriodicTransaction, 0, len(bp.journal.PeriodicTransactions()))
+	for _, pt := range bp.journal.PeriodicTransactions() {
+		if pt.Period.Interval != journal.PNone {
+			periodic = append(periodic, pt)
+		}
+	}
+	if len(periodic) == 0 {
+		return report, errors.New("no periodic transactions to compare against")
+	}
+
+	start, end, err := dateRange()
+	if err != nil {
+		return report, err
 	}
+
+	// Cover the postings where there are no bounds
+	coverStart, coverEnd := start.IsZero(), end.IsZero()
+	for _, p := range bp.postings {
+		date := p.Transaction.Date
+		if coverStart && (start.IsZero() || date.Before(start)) {
+			start = date
+		}
+		if coverEnd && !date.Before(end) {
+			end = date.AddDate(0, 0, 1)
+		}
+	}
+	if start.IsZero() || end.IsZero() {
+		return report, nil
+	}
+
+	// Use the shortest interval for the periods
+	split := periodic[0].Period
+	for _, pt := range periodic[1:] {
+		if pt.Period.Next(start).Before(split.Next(start)) {
+			split = pt.Period
+		}
+	}
+
+	for periodStart := split.Align(start); periodStart.Before(end); periodStart = split.Next(periodStart) {
+		periodEnd := split.Next(periodStart)
+
+		actual := journal.NewJournal()
+		for _, p := range bp.postings {
+			if !p.Transaction.Date.Before(periodStart) && p.Transaction.Date.Before(periodEnd) {
+				if err := actual.AddPosting(p); err != nil {
+					return report, err
+				}
+			}
+		}
+
+		budget := journal.NewJournal()
+		for _, pt := range periodic {
+			transactions := pt.Run(periodStart, periodEnd)
+			for i := range transactions {
+				_, postings := matchFilters(&transactions[i])
+				for _, p := range postings {
+					if err := budget.AddPosting(p); err != nil {
+						return report, err
+					}
+				}
+			}
+		}
+
+		if depth > 0 {
+			actual.PruneAccounts(depth)
+			budget.PruneAccounts(depth)
+		}
+
+		report.Periods = append(report.Periods, reporting.NewBudgetPerformancePeriod(periodStart, periodEnd, *actual.Root, *budget.Root))
+	}
+
+	return report, nil
 }
@@ -2,34 +2,111 @@ package cmd
 
 import (
 	"fmt"
-	"sort"
-	"strings"
+	"regexp"
 	"time"
 
 	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/parser"
+	"github.com/rikchilvers/gledger/reporting"
 	"github.com/spf13/cobra"
 )
 
+var (
+	// flag to show a single month of the budget
+	budgetMonth string
+	// flag to show the budget as a month-over-month table
+	budgetTable bool
+)
+
 var budgetCmd = &cobra.Command{
-	Use:          "budget",
+	Use:          "budget [month]",
 	Aliases:      []string{"bud", "B"},
 	Short:        "Shows budget accounts and their balances",
+	Long:         "Shows budget accounts and their balances.\nPass a month (e.g. 2020-06) to show only that month.",
 	SilenceUsage: true,
-	Run: func(_ *cobra.Command, _ []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A leading month argument selects the month rather than filtering accounts
+		if len(args) > 0 && monthArgument.MatchString(args[0]) {
+			budgetMonth = args[0]
+			args = args[1:]
+		}
+		return prepareCommand(cmd, args)
+	},
+	RunE: func(_ *cobra.Command, _ []string) error {
+		start, end, err := budgetPeriod()
+		if err != nil {
+			return err
+		}
+
 		bp := newBudgetProcessor()
 		if err := parse(bp.transactionHandler, bp.periodicTransactionHandler); err != nil {
-			fmt.Println(err)
-			return
+			return err
+		}
+		if !end.IsZero() {
+			bp.budget.ExtendTo(end.AddDate(0, 0, -1))
 		}
-		// prepareBalance(bp.journal)
-		bp.report()
+		if err := bp.budget.Calculate(); err != nil {
+			return err
+		}
+		bp.prepare()
+
+		report, err := reporting.NewBudgetReport(bp.budget, start, end)
+		if err != nil {
+			return err
+		}
+		if budgetTable {
+			return render(reporting.NewBudgetTableReport(report))
+		}
+		return render(report)
 	},
 }
 
+var monthArgument = regexp.MustCompile(`^\d{4}[-/.]\d{2}$`)
+
+// budgetPeriod works out which months of the budget to show from --month, --begin, --end and --current.
+// Zero times mean the period is unbounded.
+func budgetPeriod() (start, end time.Time, err error) {
+	if len(budgetMonth) > 0 {
+		month, err := parser.ParseSmartDate(budgetMonth)
+		if err != nil {
+			return start, end, err
+		}
+		start = journal.NormaliseToMonth(month)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	if current {
+		return start, journal.NormaliseToMonth(time.Now()).AddDate(0, 1, 0), nil
+	}
+
+	if len(beginDate) > 0 {
+		if start, err = parser.ParseSmartDate(beginDate); err != nil {
+			return start, end, err
+		}
+		start = journal.NormaliseToMonth(start)
+	}
+	if len(endDate) > 0 {
+		if end, err = parser.ParseSmartDate(endDate); err != nil {
+			return start, end, err
+		}
+		// Dates part way through a month include that month
+		if end.Day() != 1 {
+			end = journal.NormaliseToMonth(end).AddDate(0, 1, 0)
+		} else {
+			end = journal.NormaliseToMonth(end)
+		}
+	}
+
+	return start, end, nil
+}
+
 func init() {
 	budgetCmd.Flags().BoolVarP(&flattenTree, "flatten", "F", false, "show accounts as a flat list")
 	budgetCmd.Flags().BoolVarP(&showZero, "show-zero", "Z", false, "show accounts with zero amount")
 	budgetCmd.Flags().BoolVarP(&collapseOnlyChildren, "collapse", "C", false, "collapse single child accounts into a list")
+	budgetCmd.PersistentFlags().IntVarP(&depth, "depth", "d", 0, "show categories only to this depth, rolling up the amounts of deeper categories")
+	budgetCmd.Flags().StringVarP(&budgetMonth, "month", "m", "", "show only this month of the budget")
+	budgetCmd.Flags().BoolVarP(&budgetTable, "table", "T", false, "show each category's budgeted, activity and available amounts across months in a table")
 	rootCmd.AddCommand(budgetCmd)
 }
 
@@ -38,42 +115,72 @@ type budgetProcessor struct {
 }
 
 func newBudgetProcessor() budgetProcessor {
+	budget := journal.NewBudget()
+	budget.Roles = roles
 	return budgetProcessor{
-		budget: journal.NewBudget(),
+		budget: budget,
 	}
 }
 
 func (bp *budgetProcessor) periodicTransactionHandler(pt *journal.PeriodicTransaction, location string) error {
-	// return bp.journal.AddPeriodicTransaction(t, location)
+	// Only PeriodicTransactions with no interval are budget transactions.
+	// Others describe expected amounts for balance --budget and do not affect envelopes.
+	if pt.Period.Interval != journal.PNone {
+		return nil
+	}
 
-	// Convert the periodic transaction to real transactions then link them
-	// TODO take time bounds for running periodic transactions
 	transactions := pt.Run(time.Time{}, time.Time{})
+	for _, p := range filterEnvelopePostings(transactions[0].Postings) {
+		// if err := wireUpPosting(j.BudgetRoot, transaction, p); err != nil {
+		if err := bp.budget.AddPosting(p, journal.EnvelopePosting); err != nil {
+			return err
+		}
 
-	// Handle budget allocations differently
-	// PeriodicTransaction with no interval are considered budget transactions
-	if pt.Period.Interval == journal.PNone {
-		for _, p := range transactions[0].Postings {
-			// if err := wireUpPosting(j.BudgetRoot, transaction, p); err != nil {
-			if err := bp.budget.AddPosting(p, journal.EnvelopePosting); err != nil {
-				return err
+		// Goals are set by tagging the envelope's posting
+		for _, c := range p.Comments {
+			goal, found, err := parser.ParseGoal(c)
+			if err != nil {
+				return fmt.Errorf("%s\n%w", location, err)
+			}
+			if found {
+				bp.budget.AddGoal(p.AccountPath, pt.Period.StartDate, goal)
 			}
 		}
-		return nil
 	}
 
-	for _, t := range transactions {
-		bp.transactionHandler(&t, location)
+	return nil
+}
+
+// filterEnvelopePostings drops envelope postings whose expense accounts do not match the account filters.
+// Payee and note filters do not apply to envelopes so they are kept when there are no account filters.
+func filterEnvelopePostings(postings []*journal.Posting) []*journal.Posting {
+	accountFilters := make([]reporting.Filter, 0, len(filters))
+	for _, f := range filters {
+		if f.FilterType == reporting.AccountNameFilter {
+			accountFilters = append(accountFilters, f)
+		}
+	}
+	if len(accountFilters) == 0 {
+		return postings
+	}
+
+	matched := make([]*journal.Posting, 0, len(postings))
+	for _, p := range postings {
+		for _, f := range accountFilters {
+			if f.MatchesCategory(roles.Expenses, p.AccountPath) {
+				matched = append(matched, p)
+				break
+			}
+		}
 	}
 
-	return nil
+	return matched
 }
 
 func (bp *budgetProcessor) transactionHandler(t *journal.Transaction, location string) error {
-	matchedTransaction, postings, err := checkAgainstFilters(t)
-	if err != nil {
-		return err
-	}
+	// Dates are not checked here as every month is needed to work out what carries over.
+	// Instead, --begin and --end choose which months are shown.
+	matchedTransaction, postings := matchFilters(t)
 
 	if !matchedTransaction && len(postings) == 0 {
 		return nil
@@ -84,84 +191,55 @@ func (bp *budgetProcessor) transactionHandler(t *journal.Transaction, location s
 	// }
 
 	for _, p := range postings {
-		switch strings.Split(p.AccountPath, ":")[0] {
-		case journal.ExpensesID:
+		switch {
+		case roles.Expenses.Matches(p.AccountPath):
 			if err := bp.budget.AddPosting(p, journal.ExpensePosting); err != nil {
 				return err
 			}
-		case journal.IncomeID:
+		case roles.Income.Matches(p.AccountPath):
 			if err := bp.budget.AddPosting(p, journal.IncomePosting); err != nil {
 				return err
 			}
+		case roles.Liabilities.Matches(p.AccountPath):
+			// Only payments to a card from a cash account affect the budget.
+			// Spending on the card is picked up through its expense postings.
+			if !isPayment(p) {
+				continue
+			}
+			if err := bp.budget.AddPosting(p, journal.PaymentPosting); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
-func (bp *budgetProcessor) report() {
-	// sort the months
-	months := make([]time.Time, 0, len(bp.budget.Months))
-	for month := range bp.budget.Months {
-		months = append(months, month)
-	}
-	sort.Slice(months, func(i, j int) bool {
-		return months[i].Before(months[j])
-	})
-
-	for _, month := range months {
-		budget := bp.budget.Months[month]
-		fmt.Println(month)
-
-		// Padding
-		fmt.Println()
-
-		// Print the funds
-		fmt.Printf("%30s | %20s\n", budget.Income.Name, budget.Income.Amount.DisplayableQuantity(true))
-		// fmt.Printf("%30s | %20s\n", budget.ExpenseRoot.Name, budget.ExpenseRoot.Amount.DisplayableQuantity(true))
-		fmt.Printf("%30s | %20s\n", "Overspent in 2020-??", "£??.??")
-		fmt.Printf("%30s | %20s\n", "Budgeted", "£??.??")
-		fmt.Printf("%30s | %20s\n", "Budgeted in the future", "£??.??")
-		fmt.Println("-----------------------------------------------------")
-		fmt.Printf("%30s | %20s\n", "To be budgeted", "£??.??")
-
-		// Padding
-		fmt.Println()
-		fmt.Println()
-
-		fmt.Printf("%-30s | %-20s | %-20s | %-20s |\n", "Category", "Budgeted", "Activity", "Available")
-		fmt.Printf("--------------------------------------------------")
-		fmt.Printf("---------------------------------------------------\n")
-
-		// Print the envelopes
-		for _, cn := range budget.EnvelopeRoot.SortedChildNames() {
-			envelopeAccount, found := budget.EnvelopeRoot.Children[cn]
-			if !found {
-				fmt.Println("didn't find envelope account", cn)
-				return
-			}
-
-			expenseAccount, found := budget.ExpenseRoot.Children[cn]
-			if !found {
-				fmt.Println("didn't find expense account", cn)
-				return
-			}
-
-			amount := envelopeAccount.Amount
-			if err := amount.Add(expenseAccount.Amount); err != nil {
-				panic(err)
-			}
-
-			fmt.Printf("%-30s | %20s | %20s | %20s |\n", cn, expenseAccount.Amount, envelopeAccount.Amount, amount.DisplayableQuantity(true))
+// isPayment reports whether a liability posting was paid for from a cash account
+func isPayment(p *journal.Posting) bool {
+	for _, other := range p.Transaction.Postings {
+		if other != p && roles.Assets.Matches(other.AccountPath) {
+			return true
 		}
+	}
+	return false
+}
 
-		fmt.Println()
+// prepare clips the budget's categories to --depth
+func (bp *budgetProcessor) prepare() {
+	if depth <= 0 {
+		return
+	}
+	for _, bm := range bp.budget.Months {
+		bm.EnvelopeRoot.PruneChildren(depth, 0)
+		bm.ExpenseRoot.PruneChildren(depth, 0)
+		bm.CarriedRoot.PruneChildren(depth, 0)
 	}
 }
 
 func prepareBudget(j *journal.Journal) {
 	if !showZero {
-		j.Root.RemoveEmptyChildren()
+		j.RemoveEmptyAccounts()
 
 		if showBudget {
 			fmt.Println("would remove empty children from budget")
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// flag to choose the month being budgeted
+	assignMonth string
+	// flag to copy the previous month's allocations
+	assignCopy bool
+	// flag to budget what was spent in the previous month
+	assignSpent bool
+	// flag to budget the average spending over this many previous months
+	assignAverage int
+	// flag to budget what is needed to meet goals
+	assignGoals bool
+	// flag to choose the journal file the allocations are added to
+	assignPath string
+	// flag to print the allocations rather than adding them to the journal
+	assignDryRun bool
+)
+
+var budgetAssignCmd = &cobra.Command{
+	Use:   "assign [filters]",
+	Short: "Adds allocations for a month to the journal",
+	Long: "Adds a budget transaction for a month to the journal, budgeting each envelope\n" +
+		"from the previous month's allocations (--copy), the previous month's spending (--spent),\n" +
+		"the average spending over previous months (--average N) or what is needed to meet goals (--goals).",
+	SilenceUsage:      true,
+	PersistentPreRunE: prepareCommand,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return assign()
+	},
+}
+
+func init() {
+	budgetAssignCmd.Flags().StringVarP(&assignMonth, "month", "m", "", "budget this month (default the current month)")
+	budgetAssignCmd.Flags().BoolVar(&assignCopy, "copy", false, "budget what was budgeted in the previous month")
+	budgetAssignCmd.Flags().BoolVar(&assignSpent, "spent", false, "budget what was spent in the previous month")
+	budgetAssignCmd.Flags().IntVar(&assignAverage, "average", 0, "budget the average spending over this many previous months")
+	budgetAssignCmd.Flags().BoolVar(&assignGoals, "goals", false, "budget what is needed to meet goals")
+	budgetAssignCmd.Flags().StringVar(&assignPath, "to", "", "add the allocations to this journal file (default the journal read)")
+	budgetAssignCmd.Flags().BoolVarP(&assignDryRun, "dry-run", "n", false, "print the allocations rather than adding them to the journal")
+	budgetCmd.AddCommand(budgetAssignCmd)
+}
+
+func assign() error {
+	chosen := 0
+	for _, option := range []bool{assignCopy, assignSpent, assignAverage > 0, assignGoals} {
+		if option {
+			chosen++
+		}
+	}
+	if chosen != 1 {
+		return errors.New("choose one of --copy, --spent, --average or --goals")
+	}
+
+	month, err := entryMonth(assignMonth)
+	if err != nil {
+		return err
+	}
+
+	bp := newBudgetProcessor()
+	if err := parse(bp.transactionHandler, bp.periodicTransactionHandler); err != nil {
+		return err
+	}
+	bp.budget.ExtendTo(month)
+	if err := bp.budget.Calculate(); err != nil {
+		return err
+	}
+
+	var allocations map[string]int64
+	var note string
+	previous := month.AddDate(0, -1, 0)
+	switch {
+	case assignCopy:
+		allocations = bp.budget.Months[previous].Allocations()
+		note = "copied from what was budgeted in " + previous.Format("2006-01")
+	case assignSpent:
+		allocations = bp.budget.Months[previous].Spending()
+		note = "budgeted what was spent in " + previous.Format("2006-01")
+	case assignAverage > 0:
+		allocations = averageSpending(bp.budget, month, assignAverage)
+		note = fmt.Sprintf("budgeted the average spending over %d months", assignAverage)
+	case assignGoals:
+		allocations = make(map[string]int64)
+		for path, gp := range bp.budget.Months[month].Goals {
+			allocations[path] = gp.Needed.Quantity
+		}
+		note = "budgeted what is needed to meet goals"
+	}
+
+	entry := formatAllocations(month, note, allocations, bp.budget.Commodity)
+	if len(entry) == 0 {
+		fmt.Println("nothing to assign")
+		return nil
+	}
+
+	if assignDryRun {
+		fmt.Print(entry)
+		return nil
+	}
+
+	path := assignPath
+	if len(path) == 0 {
+		if path, err = journalPath(); err != nil {
+			return err
+		}
+	}
+	return appendToJournal(path, entry)
+}
+
+// averageSpending works out what each envelope spent on average over the months before month
+func averageSpending(b journal.Budget, month time.Time, months int) map[string]int64 {
+	totals := make(map[string]int64)
+	for i := 1; i <= months; i++ {
+		bm, found := b.Months[month.AddDate(0, -i, 0)]
+		if !found {
+			continue
+		}
+		for path, spent := range bm.Spending() {
+			totals[path] += spent
+		}
+	}
+
+	// Months without spending count towards the average
+	for path, total := range totals {
+		totals[path] = (total + int64(months)/2) / int64(months)
+	}
+	return totals
+}
+
+// formatAllocations formats the positive allocations as a budget transaction, returning "" if there are none
+func formatAllocations(month time.Time, note string, allocations map[string]int64, commodity string) string {
+	paths := make([]string, 0, len(allocations))
+	for path, quantity := range allocations {
+		if quantity > 0 {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	sort.Strings(paths)
+
+	postings := make([]budgetEntryPosting, 0, len(paths))
+	for _, path := range paths {
+		postings = append(postings, budgetEntryPosting{
+			envelope: path,
+			amount:   journal.NewAmount(commodity, allocations[path]),
+		})
+	}
+
+	return formatBudgetTransaction(month, note, postings)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/parser"
+	"github.com/spf13/cobra"
+)
+
+// flag to choose the month money is moved in
+var moveMonth string
+
+var budgetMoveCmd = &cobra.Command{
+	Use:          "move <amount> <from> <to>",
+	Short:        "Moves money between envelopes",
+	Long:         "Moves money from one envelope to another by adding a budget transaction to the journal.\nWhat is to be budgeted is not affected.",
+	Args:         cobra.ExactArgs(3),
+	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// The arguments are not filters
+		return prepareCommand(cmd, nil)
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		return moveMoney(args[0], args[1], args[2])
+	},
+}
+
+func init() {
+	budgetMoveCmd.Flags().StringVarP(&moveMonth, "month", "m", "", "move the money in this month (default the current month)")
+	budgetCmd.AddCommand(budgetMoveCmd)
+}
+
+func moveMoney(quantity, from, to string) error {
+	amount, err := parser.ParseCommodityAmount(quantity)
+	if err != nil {
+		return err
+	}
+	if amount.Quantity <= 0 {
+		return errors.New("the amount to move must be positive")
+	}
+	if from == to {
+		return errors.New("cannot move money to the envelope it came from")
+	}
+
+	month, err := entryMonth(moveMonth)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the journal parses before adding to it
+	bp := newBudgetProcessor()
+	if err := parse(bp.transactionHandler, bp.periodicTransactionHandler); err != nil {
+		return err
+	}
+	if amount.Commodity == "" {
+		amount.Commodity = bp.budget.Commodity
+	}
+
+	// The envelopes balance each other so what is to be budgeted is untouched.
+	// Both amounts are written so the move does not depend on how elided postings are read.
+	taken := amount
+	taken.Quantity = -amount.Quantity
+	entry := formatBudgetTransaction(month, fmt.Sprintf("move %s from %s to %s", amount.DisplayableQuantity(true), from, to), []budgetEntryPosting{
+		{envelope: to, amount: &amount},
+		{envelope: from, amount: &taken},
+	})
+
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	return appendToJournal(path, entry)
+}
+
+// entryMonth parses the month new budget transactions are added in, defaulting to the current month
+func entryMonth(month string) (time.Time, error) {
+	if len(month) == 0 {
+		return journal.NormaliseToMonth(time.Now()), nil
+	}
+	date, err := parser.ParseSmartDate(month)
+	if err != nil {
+		return date, err
+	}
+	return journal.NormaliseToMonth(date), nil
+}
+
+// budgetEntryPosting is a single envelope in a budget transaction written to the journal
+type budgetEntryPosting struct {
+	envelope string
+	amount   *journal.Amount // elided if nil
+}
+
+// formatBudgetTransaction formats a budget transaction for the month as it would be written in a journal
+func formatBudgetTransaction(month time.Time, note string, postings []budgetEntryPosting) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "~ %s\n", month.Format("2006-01"))
+	if len(note) > 0 {
+		fmt.Fprintf(&b, "    ; %s\n", note)
+	}
+
+	// Line the amounts up
+	width := 0
+	for _, p := range postings {
+		if len(p.envelope) > width {
+			width = len(p.envelope)
+		}
+	}
+
+	for _, p := range postings {
+		if p.amount == nil {
+			fmt.Fprintf(&b, "    %s\n", p.envelope)
+			continue
+		}
+		fmt.Fprintf(&b, "    %-*s    %s\n", width, p.envelope, p.amount.DisplayableQuantity(true))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/parser"
+	"github.com/rikchilvers/gledger/reporting"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// flag to run every check
+	checkStrict bool
+	// flag to allow tags without declaring them in the journal
+	checkTags []string
+)
+
+// errJournalHasProblems makes gledger exit with a non-zero status once the problems have been shown
+var errJournalHasProblems = errors.New("the journal has problems")
+
+var checkCmd = &cobra.Command{
+	Use:   "check [checks]",
+	Short: "Checks the journal for problems",
+	Long: "Parses the journal, reporting every error, and checks that balance assertions hold.\n" +
+		"Other checks can be added by name:\n\n" +
+		"  accounts     accounts are declared with 'account NAME'\n" +
+		"  commodities  commodities are declared with 'commodity SYMBOL'\n" +
+		"  payees       payees are declared with 'payee NAME'\n" +
+		"  ordered      dates are in order within each file\n" +
+		"  duplicates   no transaction appears twice\n" +
+		"  tags         tags are declared with 'tag NAME' or allowed with --tags\n\n" +
+		"Exits with a non-zero status if there are any problems.",
+	ValidArgs:    checkNames(),
+	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// The arguments are checks rather than filters
+		return prepareCommand(cmd, nil)
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		return check(args)
+	},
+}
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkStrict, "strict", false, "run every check")
+	checkCmd.Flags().StringSliceVar(&checkTags, "tags", nil, "allow these tags without declaring them")
+	rootCmd.AddCommand(checkCmd)
+}
+
+// checkNames lists the names of the checks which can be chosen
+func checkNames() []string {
+	names := make([]string, 0, len(journal.Checks))
+	for _, c := range journal.Checks {
+		if c != journal.AssertionsCheck {
+			names = append(names, string(c))
+		}
+	}
+	return names
+}
+
+func check(names []string) error {
+	checks := []journal.Check{journal.AssertionsCheck}
+	if checkStrict {
+		checks = journal.Checks
+	}
+	for _, name := range names {
+		found := false
+		for _, c := range journal.Checks {
+			if string(c) == name {
+				checks = append(checks, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown check %s (choose from %s)", name, strings.Join(checkNames(), ", "))
+		}
+	}
+
+	checker := journal.NewChecker(checks...)
+	checker.AllowTags(checkTags...)
+
+	p := parser.NewParser(checker.AddTransaction, nil)
+	p.HandleDeclarations(func(d journal.Declaration, _ string) error {
+		checker.Declare(d)
+		return nil
+	})
+	p.CollectErrors(maxErrors)
+
+	// Parse errors are reported alongside the problems the checks find
+	var diagnostics parser.Diagnostics
+	if err := parseWith(&p); err != nil {
+		var single *parser.Diagnostic
+		switch {
+		case errors.As(err, &diagnostics):
+		case errors.As(err, &single):
+			diagnostics = parser.Diagnostics{single}
+		default:
+			return err
+		}
+	}
+
+	sources := newSourceLines()
+	for _, problem := range checker.Run() {
+		diagnostics = append(diagnostics, parser.NewDiagnostic(problem.Path, problem.Line, sources.line(problem.Path, problem.Line), problem.Message, problem.Hint))
+	}
+
+	if err := render(reporting.NewCheckReport(diagnostics)); err != nil {
+		return err
+	}
+	if len(diagnostics) > 0 {
+		return errJournalHasProblems
+	}
+	return nil
+}
+
+// sourceLines reads the lines of journal files so problems can be shown with the line they are on
+type sourceLines map[string][]string
+
+func newSourceLines() sourceLines {
+	return make(sourceLines)
+}
+
+// line returns the line of the file (the first line is 1), or "" if it cannot be read
+func (s sourceLines) line(path string, line int) string {
+	lines, found := s[path]
+	if !found {
+		contents, err := ioutil.ReadFile(path)
+		if err == nil {
+			lines = strings.Split(string(contents), "\n")
+		}
+		s[path] = lines
+	}
+
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line-1], "\r")
+}
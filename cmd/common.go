@@ -2,8 +2,9 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -12,48 +13,120 @@ import (
 	"github.com/rikchilvers/gledger/reporting"
 )
 
-func parse(th parser.TransactionHandler, ph parser.PeriodicTransactionHandler) error {
-	if len(rootJournalPath) == 0 {
+// stdinPath is the --file which reads the journal from stdin
+const stdinPath = "-"
+
+// stdinLocation is how a journal read from stdin is referred to
+const stdinLocation = "<stdin>"
+
+// journalPaths returns the paths of the root journals from --file or $LEDGER_FILE
+func journalPaths() ([]string, error) {
+	if len(rootJournalPaths) == 0 {
 		path, found := os.LookupEnv("LEDGER_FILE")
 		if !found {
-			return errors.New("no root journal path provided")
+			return nil, errors.New("no root journal path provided")
 		}
-		rootJournalPath = path
+		rootJournalPaths = []string{path}
 	}
 
-	file, err := os.Open(rootJournalPath)
+	stdin := 0
+	for _, path := range rootJournalPaths {
+		if path == stdinPath {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return nil, errors.New("stdin can only be read once")
+	}
+	return rootJournalPaths, nil
+}
+
+// journalPath returns the path of the first root journal, which is where new entries are added
+func journalPath() (string, error) {
+	paths, err := journalPaths()
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer file.Close()
+	if paths[0] == stdinPath {
+		return "", errors.New("cannot add to a journal read from stdin")
+	}
+	return paths[0], nil
+}
 
+// parse reads the journals, passing each transaction to the handlers.
+// Every error in the journals (up to --max-errors) is returned so they can be fixed in one go.
+// Commands should not report anything if there are errors.
+func parse(th parser.TransactionHandler, ph parser.PeriodicTransactionHandler) error {
 	p := parser.NewParser(th, ph)
-	if err := p.Parse(file, rootJournalPath); err != nil {
+	p.CollectErrors(maxErrors)
+	return parseWith(&p)
+}
+
+// parseWith reads the journals in turn with a parser which has already been set up
+func parseWith(p *parser.Parser) error {
+	paths, err := journalPaths()
+	if err != nil {
 		return err
 	}
-	return nil
+
+	for _, path := range paths {
+		// Parse errors from one journal are returned along with those from the journals after it
+		err = parseJournal(p, path)
+		var collected parser.Diagnostics
+		if err != nil && !errors.As(err, &collected) {
+			return err
+		}
+	}
+	return err
 }
 
-// report prints the given account and it's descendents
-// TODO: move this to reporting package
-func report(account journal.Account, flattenTree, shouldCollapseOnlyChildren bool) {
-	prepender := func(a journal.Account) string {
-		return fmt.Sprintf("%20s  ", a.Amount.DisplayableQuantity(true))
+// parseJournal reads a single journal (or stdin)
+func parseJournal(p *parser.Parser, path string) error {
+	if path == stdinPath {
+		return p.Parse(os.Stdin, stdinLocation)
 	}
 
-	if flattenTree {
-		flattened := reporting.FlattenedTree(account, prepender)
-		fmt.Println(flattened)
-	} else {
-		tree := reporting.Tree(account, prepender, shouldCollapseOnlyChildren)
-		fmt.Println(tree)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	// 20x '-' because that is how wide we format the amount to be
-	fmt.Println("--------------------")
+	return p.Parse(file, path)
+}
 
-	// Print the root account's value
-	fmt.Printf("%20s\n", account.Amount.DisplayableQuantity(false))
+// appendToJournal adds an entry to the end of the journal at path, separated from what is already there by a blank line
+func appendToJournal(path string, entry string) error {
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	separator := ""
+	if len(existing) > 0 {
+		separator = "\n"
+		if !bytes.HasSuffix(existing, []byte("\n")) {
+			separator = "\n\n"
+		} else if bytes.HasSuffix(existing, []byte("\n\n")) {
+			separator = ""
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(separator + entry); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// render writes the report to stdout (or the file given by --output)
+func render(r reporting.Report) error {
+	return output.Write(r)
 }
 
 // dateCheckedTransactionHandler wraps a transaction handler in --begin / --end checks
@@ -97,27 +170,31 @@ func dateCheckedFilteringTransactionHandler(handler func(t *journal.Transaction,
 	}
 }
 
-func withinDateRange(t *journal.Transaction) (bool, error) {
-	var err error
-	var start, end time.Time
+// dateRange parses --begin, --end and --current. Zero times mean the range is unbounded.
+func dateRange() (start, end time.Time, err error) {
+	if current {
+		return start, time.Now().AddDate(0, 0, 1), nil
+	}
 
-	if len(beginDate) > 0 && !current {
-		start, err = parser.ParseSmartDate(beginDate)
-		if err != nil {
-			return false, err
+	if len(beginDate) > 0 {
+		if start, err = parser.ParseSmartDate(beginDate); err != nil {
+			return start, end, err
 		}
 	}
 
-	if len(endDate) > 0 && !current {
-		end, err = parser.ParseSmartDate(endDate)
-		if err != nil {
-			return false, err
+	if len(endDate) > 0 {
+		if end, err = parser.ParseSmartDate(endDate); err != nil {
+			return start, end, err
 		}
 	}
 
-	if current {
-		start = time.Time{}
-		end = time.Now().AddDate(0, 0, 1)
+	return start, end, nil
+}
+
+func withinDateRange(t *journal.Transaction) (bool, error) {
+	start, end, err := dateRange()
+	if err != nil {
+		return false, err
 	}
 
 	withinRange := (t.Date.Equal(start) || t.Date.After(start)) && (end.IsZero() || t.Date.Before(end))
@@ -137,8 +214,14 @@ func checkAgainstFilters(t *journal.Transaction) (matchedTransaction bool, posti
 		return false, nil, nil
 	}
 
+	matchedTransaction, postings = matchFilters(t)
+	return matchedTransaction, postings, nil
+}
+
+// matchFilters is checkAgainstFilters without the --begin / --end checks
+func matchFilters(t *journal.Transaction) (matchedTransaction bool, postings []*journal.Posting) {
 	if len(filters) == 0 {
-		return true, t.Postings, nil
+		return true, t.Postings
 	}
 
 	matchedPostings := make(map[*journal.Posting]bool)
@@ -147,16 +230,19 @@ func checkAgainstFilters(t *journal.Transaction) (matchedTransaction bool, posti
 
 		if matchesPayee || matchesTransactionNote {
 			// If we've matched on something transaction-wide, we can return everything here
-			return true, t.Postings, nil
+			return true, t.Postings
 		}
 
 		for _, p := range mp {
 			matchedPostings[p] = true
 		}
 	}
-	for k := range matchedPostings {
-		postings = append(postings, k)
+	// Keep the postings in the order they appear in the transaction
+	for _, p := range t.Postings {
+		if matchedPostings[p] {
+			postings = append(postings, p)
+		}
 	}
 
-	return false, postings, nil
+	return false, postings
 }
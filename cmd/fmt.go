@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"unicode/utf8"
+
+	"github.com/rikchilvers/gledger/parser"
+	"github.com/rikchilvers/gledger/shared"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// flag to list the files which need formatting rather than formatting them
+	fmtCheck bool
+	// flag for the column amounts end at
+	fmtColumn int
+	// flag for how many spaces postings are indented by
+	fmtIndent int
+	// flag for the separator dates are written with
+	fmtDateSeparator string
+	// flag to sort transactions by date
+	fmtSort bool
+)
+
+// errJournalNeedsFormatting makes gledger exit with a non-zero status once the files have been listed
+var errJournalNeedsFormatting = errors.New("the journal needs formatting")
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [files]",
+	Short: "Formats journal files",
+	Long: "Rewrites journal files with aligned amounts, consistent dates and indentation, and single blank lines between transactions.\n" +
+		"Comments are kept. Formats the root journals and the files they include unless files are given.\n" +
+		"A journal read from stdin is written to stdout.\n\n" +
+		"With --check, lists the files which need formatting and exits with a non-zero status if there are any.",
+	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// The arguments are files rather than filters
+		return prepareCommand(cmd, nil)
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		return formatJournals(args)
+	},
+}
+
+func init() {
+	defaults := parser.DefaultFormatOptions()
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "list the files which need formatting without changing them")
+	fmtCmd.Flags().IntVar(&fmtColumn, "column", defaults.Column, "end amounts at this column")
+	fmtCmd.Flags().IntVar(&fmtIndent, "indent", defaults.Indent, "indent postings by this many spaces")
+	fmtCmd.Flags().StringVar(&fmtDateSeparator, "date-separator", string(defaults.DateSeparator), "separate dates with -, / or .")
+	fmtCmd.Flags().BoolVar(&fmtSort, "sort", false, "sort transactions by date")
+	rootCmd.AddCommand(fmtCmd)
+}
+
+func formatJournals(paths []string) error {
+	options, err := formatOptions()
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		roots, err := journalPaths()
+		if err != nil {
+			return err
+		}
+		if paths, err = journalFiles(roots); err != nil {
+			return err
+		}
+	}
+
+	unformatted := false
+	for _, path := range paths {
+		var src []byte
+		location := path
+		if path == stdinPath {
+			src, err = ioutil.ReadAll(os.Stdin)
+			location = stdinLocation
+		} else {
+			src, err = ioutil.ReadFile(path)
+		}
+		if err != nil {
+			return err
+		}
+
+		formatted, err := parser.Format(src, location, options)
+		if err != nil {
+			// Diagnostics already name the file and are rendered as they are by the other commands
+			var diagnostics parser.Diagnostics
+			if errors.As(err, &diagnostics) {
+				return diagnostics
+			}
+			return fmt.Errorf("%s: %w", location, err)
+		}
+		if path == stdinPath && !fmtCheck {
+			if _, err := os.Stdout.Write(formatted); err != nil {
+				return err
+			}
+			continue
+		}
+		if bytes.Equal(src, formatted) {
+			continue
+		}
+
+		if fmtCheck {
+			fmt.Println(path)
+			unformatted = true
+			continue
+		}
+		if err := writeAtomically(path, formatted); err != nil {
+			return err
+		}
+	}
+
+	if unformatted {
+		return errJournalNeedsFormatting
+	}
+	return nil
+}
+
+func formatOptions() (parser.FormatOptions, error) {
+	options := parser.DefaultFormatOptions()
+	options.Column = fmtColumn
+	options.Indent = fmtIndent
+	options.Sort = fmtSort
+
+	if utf8.RuneCountInString(fmtDateSeparator) != 1 {
+		return options, fmt.Errorf("dates cannot be separated by %q (use -, / or .)", fmtDateSeparator)
+	}
+	options.DateSeparator, _ = utf8.DecodeRuneInString(fmtDateSeparator)
+	return options, nil
+}
+
+// journalFiles lists the root journals and every file they include
+func journalFiles(roots []string) ([]string, error) {
+	files := make([]string, 0, 4)
+	seen := make(map[string]bool)
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		files = append(files, path)
+		if path == stdinPath {
+			// Files included from stdin are not followed
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range parser.Includes(src) {
+			included, err := parser.ResolveInclude(path, pattern)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			for _, file := range included {
+				if err := walk(file); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, root := range roots {
+		if err := walk(root); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// writeAtomically replaces the file at path with contents, keeping its permissions
+func writeAtomically(path string, contents []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return shared.WriteFileAtomically(path, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := w.Write(contents)
+		return err
+	})
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"regexp"
 	"sort"
 
@@ -19,18 +18,16 @@ var payeesCmd = &cobra.Command{
 	Aliases:      []string{"pay", "p"},
 	Short:        "List all payees",
 	SilenceUsage: true,
-	Run: func(_ *cobra.Command, args []string) {
+	RunE: func(_ *cobra.Command, args []string) error {
 		pj := newPayeesJournal()
 		th := dateCheckedTransactionHandler(pj.transactionHandler)
 		if err := parse(th, nil); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 		if err := pj.prepare(args); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
-		pj.report()
+		return render(reporting.PayeesReport{Payees: pj.payees})
 	},
 }
 
@@ -89,9 +86,3 @@ payeesLoop:
 
 	return nil
 }
-
-func (pj *payeesJournal) report() {
-	for _, a := range pj.payees {
-		fmt.Println(a)
-	}
-}
@@ -1,10 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"sort"
 
 	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/reporting"
 	"github.com/spf13/cobra"
 )
 
@@ -17,14 +17,13 @@ var printCmd = &cobra.Command{
 	Aliases:      []string{"p"},
 	Short:        "Shows transaction entries, sorted by date",
 	SilenceUsage: true,
-	Run: func(_ *cobra.Command, _ []string) {
+	RunE: func(_ *cobra.Command, _ []string) error {
 		pj := newPrintJournal()
 		if err := parse(pj.transactionHandler, nil); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 		pj.prepare()
-		pj.report()
+		return render(reporting.NewPrintReport(pj.transactions))
 	},
 }
 
@@ -56,9 +55,3 @@ func (pj *printJournal) prepare() {
 		return pj.transactions[i].Date.Before(pj.transactions[j].Date)
 	})
 }
-
-func (pj *printJournal) report() {
-	for _, t := range pj.transactions {
-		fmt.Println(t)
-	}
-}
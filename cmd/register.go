@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/reporting"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	registerCmd.PersistentFlags().IntVarP(&depth, "depth", "d", 0, "show account names only to this depth")
+	rootCmd.AddCommand(registerCmd)
+}
+
+var registerCmd = &cobra.Command{
+	Use:          "register",
+	Aliases:      []string{"reg", "r"},
+	Short:        "Shows postings and a running total, sorted by date",
+	SilenceUsage: true,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		rj := newRegisterJournal()
+		if err := parse(rj.transactionHandler, nil); err != nil {
+			return err
+		}
+		rj.prepare()
+		return render(reporting.NewRegisterReport(rj.postings, depth))
+	},
+}
+
+type registerJournal struct {
+	postings []*journal.Posting
+}
+
+func newRegisterJournal() registerJournal {
+	return registerJournal{
+		postings: make([]*journal.Posting, 0, 2056),
+	}
+}
+
+func (rj *registerJournal) transactionHandler(t *journal.Transaction, _ string) error {
+	_, postings, err := checkAgainstFilters(t)
+	if err != nil {
+		return err
+	}
+
+	rj.postings = append(rj.postings, postings...)
+
+	return nil
+}
+
+func (rj *registerJournal) prepare() {
+	// Stable so postings from the same transaction stay together and in order
+	sort.SliceStable(rj.postings, func(i, j int) bool {
+		return rj.postings[i].Transaction.Date.Before(rj.postings[j].Transaction.Date)
+	})
+}
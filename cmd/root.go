@@ -1,55 +1,119 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/rikchilvers/gledger/journal"
 	"github.com/rikchilvers/gledger/reporting"
 	"github.com/spf13/cobra"
 )
 
 var (
-	// flag to pass the journal file to read
-	rootJournalPath string
+	// flag to pass the journal files to read
+	rootJournalPaths []string
 	// flag to include only transactions on or after this date
 	beginDate string
 	// flag to include only transactions before this date
 	endDate string
 	// flag to include only transactions on or before today
 	current bool
-	filters []reporting.Filter
+	// flag to choose how reports are rendered
+	outputFormat string
+	// flag to write reports to a file rather than stdout
+	outputPath string
+	// flag to choose how many parse errors are reported before giving up
+	maxErrors int
+	// flags to choose the accounts the budget treats as income, expenses, cash and credit cards
+	incomeAccounts    []string
+	expenseAccounts   []string
+	assetAccounts     []string
+	liabilityAccounts []string
+	filters           []reporting.Filter
+	output            reporting.Output
+	roles             = journal.DefaultAccountRoles()
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "gledger",
-	Short: "gledger - command line budgeting",
-	Long:  "gledger is a reimplementation of Ledger\nwith YNAB-style budgeting at its core",
-	PersistentPreRunE: func(_ *cobra.Command, args []string) error {
-		filters = make([]reporting.Filter, 0, len(args))
-		for _, arg := range args {
-			filter, err := reporting.NewFilter(arg)
-			if err != nil {
-				return err
-			}
-			filters = append(filters, filter)
-		}
-		return nil
-	},
-	Run: func(cmd *cobra.Command, _ []string) {
-		cmd.Help()
+	Use:               "gledger",
+	Short:             "gledger - command line budgeting",
+	Long:              "gledger is a reimplementation of Ledger\nwith YNAB-style budgeting at its core",
+	SilenceErrors:     true, // Execute shows them
+	PersistentPreRunE: prepareCommand,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return cmd.Help()
 	},
 }
 
+// prepareCommand sets up the output and filters shared by all commands
+func prepareCommand(cmd *cobra.Command, args []string) error {
+	format, err := reporting.ParseFormat(outputFormat)
+	if err != nil {
+		return err
+	}
+	// Unless a format was chosen, infer it from the output file's extension
+	if inferred, ok := reporting.FormatForPath(outputPath); ok && !cmd.Flags().Changed("output-format") {
+		format = inferred
+	}
+	output = reporting.NewOutput(outputPath, format)
+
+	filters = make([]reporting.Filter, 0, len(args))
+	for _, arg := range args {
+		filter, err := reporting.NewFilter(arg)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
+	return prepareRoles()
+}
+
+// prepareRoles builds the account roles, keeping the default for any role whose flag was not set
+func prepareRoles() error {
+	roles = journal.DefaultAccountRoles()
+	for _, role := range []struct {
+		specs []string
+		set   *journal.AccountSet
+	}{
+		{incomeAccounts, &roles.Income},
+		{expenseAccounts, &roles.Expenses},
+		{assetAccounts, &roles.Assets},
+		{liabilityAccounts, &roles.Liabilities},
+	} {
+		if len(role.specs) == 0 {
+			continue
+		}
+		set, err := journal.NewAccountSet(role.specs...)
+		if err != nil {
+			return err
+		}
+		*role.set = set
+	}
+	return nil
+}
+
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&rootJournalPath, "file", "f", "", "journal file to read (default $LEDGER_FILE)")
+	rootCmd.PersistentFlags().StringArrayVarP(&rootJournalPaths, "file", "f", nil, "journal file to read, repeatable, - for stdin (default $LEDGER_FILE)")
 	rootCmd.PersistentFlags().StringVarP(&beginDate, "begin", "b", "", "include only transactions on or after this date")
 	rootCmd.PersistentFlags().StringVarP(&endDate, "end", "e", "", "include only transactions before this date")
 	rootCmd.PersistentFlags().BoolVarP(&current, "current", "c", false, "include only transactions on or before today (overrides --begin and --end)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "O", "text", "render reports as text, json, csv, tsv or html")
+	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "write reports to this file rather than stdout (inferring the format from its extension)")
+	rootCmd.PersistentFlags().IntVar(&maxErrors, "max-errors", 10, "report at most this many errors in the journal (0 for no limit)")
+	rootCmd.PersistentFlags().StringArrayVar(&incomeAccounts, "income", nil, "treat this account root or /regex/ as income (repeatable, default Income)")
+	rootCmd.PersistentFlags().StringArrayVar(&expenseAccounts, "expenses", nil, "treat this account root or /regex/ as expenses (repeatable, default Expenses)")
+	rootCmd.PersistentFlags().StringArrayVar(&assetAccounts, "assets", nil, "treat this account root or /regex/ as on-budget cash (repeatable, default Assets)")
+	rootCmd.PersistentFlags().StringArrayVar(&liabilityAccounts, "liabilities", nil, "treat this account root or /regex/ as credit cards (repeatable, default Liabilities)")
 }
 
-// Execute runs gledger
+// Execute runs gledger, showing any error and exiting with a non-zero status if the command failed
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		// fmt.Fprintln(os.Stderr, err)
+		// These only set the exit status as the problems have already been shown
+		if err != errJournalHasProblems && err != errJournalNeedsFormatting {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
@@ -3,6 +3,7 @@ package journal
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/rikchilvers/gledger/shared"
 )
@@ -24,11 +25,12 @@ func NewAccount(name string) *Account {
 	return &Account{
 		Name:           name,
 		Path:           "",
-		PathComponents: make([]string, 0, 5),
+		PathComponents: nil,
 		Parent:         nil,
 		Children:       make(map[string]*Account),
-		Postings:       make([]*Posting, 0, 2048),
-		Transactions:   make([]*Transaction, 0, 1024),
+		// Most accounts have few postings so these grow as they are added
+		Postings:     nil,
+		Transactions: nil,
 	}
 }
 
@@ -101,7 +103,10 @@ func newAccountWithChildren(parent *Account, components []string) *Account {
 	if parent == nil || parent.Name == RootID || parent.Name == BudgetRootID {
 		child.PathComponents = []string{components[0]}
 	} else {
-		child.PathComponents = append(parent.PathComponents, components[0])
+		// Copy the parent's components so that siblings don't share a backing array
+		child.PathComponents = make([]string, 0, len(parent.PathComponents)+1)
+		child.PathComponents = append(child.PathComponents, parent.PathComponents...)
+		child.PathComponents = append(child.PathComponents, components[0])
 	}
 
 	child.Path = child.CreatePath()
@@ -148,19 +153,49 @@ func (a *Account) SortedChildNames() []string {
 	return names
 }
 
-// PruneChildren removes child nodes beneath a certain depth
+// PruneChildren removes child nodes beneath a certain depth.
+// Amounts are already rolled up into ancestors so pruned accounts keep their totals
+// but the postings and transactions of removed descendants are moved up into the pruned account.
 func (a *Account) PruneChildren(targetDepth, currentDepth int) {
 	// If we've reached the target depth, remove all children
 	if currentDepth == targetDepth {
-		for key := range a.Children {
+		// Transactions which post to several of the descendants are only held once
+		held := make(map[*Transaction]bool, len(a.Transactions))
+		for _, t := range a.Transactions {
+			held[t] = true
+		}
+		for key, child := range a.Children {
+			child.PruneChildren(targetDepth, currentDepth)
+			a.Postings = append(a.Postings, child.Postings...)
+			for _, t := range child.Transactions {
+				if !held[t] {
+					held[t] = true
+					a.Transactions = append(a.Transactions, t)
+				}
+			}
+			child.Parent = nil
 			delete(a.Children, key)
 		}
+		return
 	}
 	for _, child := range a.Children {
 		child.PruneChildren(targetDepth, currentDepth+1)
 	}
 }
 
+// ClipPath shortens a : delimited account path to the given number of components.
+// A depth of zero or less leaves the path untouched.
+func ClipPath(path string, depth int) string {
+	if depth <= 0 {
+		return path
+	}
+	components := strings.SplitN(path, ":", depth+1)
+	if len(components) <= depth {
+		return path
+	}
+	return strings.Join(components[:depth], ":")
+}
+
 // Leaves finds all accounts with no children
 func (a *Account) Leaves() []*Account {
 	matcher := func(a Account) bool {
@@ -189,17 +224,13 @@ func (a *Account) findAccounts(matcher func(a Account) bool, found []*Account) [
 	return found
 }
 
+// RemoveEmptyChildren removes descendants with a zero amount.
+// Accounts which total zero but have non-zero descendants are kept so that their children can still be shown.
 func (a *Account) RemoveEmptyChildren() {
 	matcher := func(a Account) bool {
-		return a.Amount.Quantity == 0
-	}
-	matching := a.FindAccounts(matcher)
-	for _, m := range matching {
-		if m.Name == RootID {
-			continue
-		}
-		m.Unlink()
+		return a.Amount.Quantity != 0
 	}
+	a.RemoveChildren(matcher)
 }
 
 // Unlink removes this account from it's parents
@@ -233,3 +264,45 @@ func (a *Account) RemoveChildren(matcher func(a Account) bool) bool {
 
 	return matches
 }
+
+// walk calls action on this account and all its descendants
+func (a *Account) walk(action func(*Account)) {
+	action(a)
+	for _, child := range a.Children {
+		child.walk(action)
+	}
+}
+
+// findAccount returns the descendant matching the path components (or nil if there is not one)
+func (a *Account) findAccount(components []string) *Account {
+	if len(components) == 0 {
+		return nil
+	}
+	child, remaining := a.findChild(components)
+	if remaining != nil {
+		return nil
+	}
+	return child
+}
+
+// ownAmount is the account's amount excluding the amounts of its descendants
+func (a *Account) ownAmount() int64 {
+	own := a.Amount.Quantity
+	for _, child := range a.Children {
+		own -= child.Amount.Quantity
+	}
+	return own
+}
+
+// addToAccount finds or creates the descendant matching the components
+// then adds the amount to it and all of its ancestors
+func (a *Account) addToAccount(components []string, amount Amount) (*Account, error) {
+	account := a.FindOrCreateAccount(components)
+	err := account.WalkAncestors(func(a *Account) error {
+		if a.Amount.Commodity == "" {
+			a.Amount.Commodity = amount.Commodity
+		}
+		return a.Amount.Add(amount)
+	})
+	return account, err
+}
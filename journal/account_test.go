@@ -316,3 +316,93 @@ func TestRemoveChildren(t *testing.T) {
 		t.Fatalf("did not find groceries")
 	}
 }
+
+func TestRemoveEmptyChildrenKeepsNonEmptyDescendants(t *testing.T) {
+	root := newTestAccount()
+
+	// Assets totals zero but its children do not
+	root.FindOrCreateAccount([]string{"Assets", "Current"}).WalkAncestors(func(a *Account) error {
+		a.Amount.Quantity += 100
+		return nil
+	})
+	root.FindOrCreateAccount([]string{"Assets", "Savings", "ISA"}).WalkAncestors(func(a *Account) error {
+		a.Amount.Quantity -= 100
+		return nil
+	})
+
+	root.RemoveEmptyChildren()
+
+	assets, ok := root.Children["Assets"]
+	if !ok {
+		t.Fatalf("removed assets despite it having non-empty children")
+	}
+	if len(assets.Children) != 2 {
+		t.Fatalf("expected assets to have 2 children, got %d", len(assets.Children))
+	}
+	if _, ok := root.Children["Expenses"]; ok {
+		t.Fatalf("did not remove empty expenses")
+	}
+}
+
+func TestPruningKeepsRolledUpAmounts(t *testing.T) {
+	root := newTestAccount()
+	transaction := NewTransaction()
+
+	for _, path := range []string{"Expenses:Fun:Hobbies", "Expenses:Fun:Dining Out", "Expenses:Life:Groceries"} {
+		p := NewPosting()
+		p.AccountPath = path
+		p.Amount = NewAmount("£", 100)
+		if err := wireUpPosting(root, &transaction, p); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	root.PruneChildren(2, 0)
+
+	fun := root.Children["Expenses"].Children["Fun"]
+	if len(fun.Children) != 0 {
+		t.Fatalf("did not prune children of fun")
+	}
+	if fun.Amount.Quantity != 200 {
+		t.Fatalf("pruned account lost its total: expected 200, got %d", fun.Amount.Quantity)
+	}
+	if len(fun.Postings) != 2 {
+		t.Fatalf("pruned account did not take its descendants' postings: expected 2, got %d", len(fun.Postings))
+	}
+	if len(fun.Transactions) != 1 {
+		t.Fatalf("pruned account should hold a transaction posting to several descendants once: got %d", len(fun.Transactions))
+	}
+	if root.Children["Expenses"].Amount.Quantity != 300 {
+		t.Fatalf("expenses total changed when pruning")
+	}
+}
+
+func TestClipPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		depth    int
+		expected string
+	}{
+		{"Expenses:Fun:Hobbies", 0, "Expenses:Fun:Hobbies"},
+		{"Expenses:Fun:Hobbies", 1, "Expenses"},
+		{"Expenses:Fun:Hobbies", 2, "Expenses:Fun"},
+		{"Expenses:Fun:Hobbies", 3, "Expenses:Fun:Hobbies"},
+		{"Expenses:Fun:Hobbies", 4, "Expenses:Fun:Hobbies"},
+	}
+
+	for _, test := range tests {
+		if got := ClipPath(test.path, test.depth); got != test.expected {
+			t.Fatalf("clipping '%s' to %d: expected '%s', got '%s'", test.path, test.depth, test.expected, got)
+		}
+	}
+}
+
+func TestSiblingPathComponents(t *testing.T) {
+	root := NewAccount("Spending")
+	clothing := root.FindOrCreateAccount([]string{"Clothing"})
+	root.FindOrCreateAccount([]string{"Groceries"})
+
+	if !equal(clothing.PathComponents, []string{"Clothing"}) {
+		t.Fatalf("sibling overwrote path components: %v", clothing.PathComponents)
+	}
+}
@@ -0,0 +1,150 @@
+package journal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AccountSet matches accounts by their root (e.g. Expenses or Expenses:Budgeted) or by a regular expression
+type AccountSet struct {
+	roots    []string
+	patterns []*regexp.Regexp
+}
+
+// NewAccountSet creates an AccountSet from roots and regular expressions wrapped in slashes (e.g. /^(Bills|Spending)/)
+func NewAccountSet(specs ...string) (AccountSet, error) {
+	s := AccountSet{}
+	for _, spec := range specs {
+		if len(spec) > 2 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+			pattern, err := regexp.Compile(spec[1 : len(spec)-1])
+			if err != nil {
+				return s, fmt.Errorf("error parsing account pattern %s: %w", spec, err)
+			}
+			s.patterns = append(s.patterns, pattern)
+			continue
+		}
+		if len(spec) > 0 {
+			s.roots = append(s.roots, strings.TrimSuffix(spec, ":"))
+		}
+	}
+	return s, nil
+}
+
+// mustNewAccountSet creates an AccountSet from roots, which cannot fail
+func mustNewAccountSet(roots ...string) AccountSet {
+	s, _ := NewAccountSet(roots...)
+	return s
+}
+
+// Matches reports whether the account at path is in the set
+func (s AccountSet) Matches(path string) bool {
+	for _, root := range s.roots {
+		if path == root || strings.HasPrefix(path, root+":") {
+			return true
+		}
+	}
+	for _, pattern := range s.patterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Category returns the path components below the root the account at path matched.
+// For regular expressions, the root is the components up to the end of the match.
+// Returns nil if the path does not match or is the root itself.
+func (s AccountSet) Category(path string) []string {
+	split := func(rest string) []string {
+		if len(rest) == 0 {
+			return nil
+		}
+		return strings.Split(rest, ":")
+	}
+
+	for _, root := range s.roots {
+		if strings.HasPrefix(path, root+":") {
+			return split(path[len(root)+1:])
+		}
+		if path == root {
+			return nil
+		}
+	}
+
+	for _, pattern := range s.patterns {
+		match := pattern.FindStringIndex(path)
+		if match == nil {
+			continue
+		}
+		end := match[1]
+		if end > 0 && path[end-1] == ':' {
+			return split(path[end:])
+		}
+		next := strings.IndexByte(path[end:], ':')
+		if next == -1 {
+			return nil
+		}
+		return split(path[end+next+1:])
+	}
+
+	return nil
+}
+
+// CategoryOrPath returns the category of the account at path, which is its envelope in the budget.
+// Accounts with nothing below the part the set matched (such as the root itself) are their own category,
+// so accounts which only differ before the end of the match are kept apart.
+func (s AccountSet) CategoryOrPath(path string) []string {
+	if category := s.Category(path); len(category) > 0 {
+		return category
+	}
+	return strings.Split(path, ":")
+}
+
+// RootPaths returns the paths an account with a category has under each of the set's roots.
+// Accounts matched by regular expressions cannot be worked out from their category so are not included.
+func (s AccountSet) RootPaths(category string) []string {
+	paths := make([]string, len(s.roots))
+	for i, root := range s.roots {
+		paths[i] = root + ":" + category
+	}
+	return paths
+}
+
+// FoldCase returns a copy of the set which matches paths in lower case, for comparing with case insensitive filters
+func (s AccountSet) FoldCase() AccountSet {
+	folded := AccountSet{}
+	for _, root := range s.roots {
+		folded.roots = append(folded.roots, strings.ToLower(root))
+	}
+	for _, pattern := range s.patterns {
+		folded.patterns = append(folded.patterns, regexp.MustCompile("(?i)"+pattern.String()))
+	}
+	return folded
+}
+
+// First returns the first root in the set, or fallback if it only has regular expressions
+func (s AccountSet) First(fallback string) string {
+	if len(s.roots) > 0 {
+		return s.roots[0]
+	}
+	return fallback
+}
+
+// AccountRoles says which accounts are treated as income, expenses, cash and credit
+type AccountRoles struct {
+	Income      AccountSet
+	Expenses    AccountSet
+	Assets      AccountSet // cash accounts which are on budget
+	Liabilities AccountSet // credit cards
+}
+
+// DefaultAccountRoles uses the Income, Expenses, Assets and Liabilities roots
+func DefaultAccountRoles() AccountRoles {
+	return AccountRoles{
+		Income:      mustNewAccountSet(IncomeID),
+		Expenses:    mustNewAccountSet(ExpensesID),
+		Assets:      mustNewAccountSet(AssetsID),
+		Liabilities: mustNewAccountSet(LiabilitiesID),
+	}
+}
@@ -0,0 +1,64 @@
+package journal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccountSetMatches(t *testing.T) {
+	s, err := NewAccountSet("Spending", "Bills:", "/^Savings:(Holiday|Car)/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		matches bool
+	}{
+		{"Spending", true},
+		{"Spending:Food", true},
+		{"SpendingMoney:Food", false},
+		{"Bills:Rent", true},
+		{"Savings:Holiday:Flights", true},
+		{"Savings:Pension", false},
+		{"Expenses:Food", false},
+	}
+
+	for _, test := range tests {
+		if got := s.Matches(test.path); got != test.matches {
+			t.Errorf("%s: expected %t, got %t", test.path, test.matches, got)
+		}
+	}
+}
+
+func TestAccountSetCategory(t *testing.T) {
+	s, err := NewAccountSet("Spending:Budgeted", "/^Savings:(Holiday|Car)/", "/^Bills:/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		category []string
+	}{
+		{"Spending:Budgeted:Food:Groceries", []string{"Food", "Groceries"}},
+		{"Spending:Budgeted", nil},
+		{"Savings:Holiday:Flights", []string{"Flights"}},
+		{"Savings:Holiday", nil},
+		{"Savings:Car:Insurance:2020", []string{"Insurance", "2020"}},
+		{"Bills:Rent", []string{"Rent"}},
+		{"Expenses:Food", nil},
+	}
+
+	for _, test := range tests {
+		if got := s.Category(test.path); !reflect.DeepEqual(got, test.category) {
+			t.Errorf("%s: expected %v, got %v", test.path, test.category, got)
+		}
+	}
+}
+
+func TestNewAccountSetRejectsBadPatterns(t *testing.T) {
+	if _, err := NewAccountSet("/(Spending/"); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+}
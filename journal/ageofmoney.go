@@ -0,0 +1,94 @@
+package journal
+
+import (
+	"sort"
+	"time"
+)
+
+// ageOfMoneyOutflows is how many of the latest outflows are averaged for the age of money
+const ageOfMoneyOutflows = 10
+
+// CashFlow is money coming into (positive) or going out of (negative) cash accounts
+type CashFlow struct {
+	Date     time.Time
+	Quantity int64
+}
+
+// CashFlowOf returns the change a transaction makes to the cash accounts.
+// Transfers between cash accounts do not change it.
+func CashFlowOf(t *Transaction, cash AccountSet) CashFlow {
+	flow := CashFlow{Date: t.Date}
+	for _, p := range t.Postings {
+		if p.Amount != nil && cash.Matches(p.AccountPath) {
+			flow.Quantity += p.Amount.Quantity
+		}
+	}
+	return flow
+}
+
+// AgeOfMoneyPoint is the age of money after an outflow
+type AgeOfMoneyPoint struct {
+	Date time.Time
+	Days float64
+}
+
+// AgeOfMoney works out how long money sits in cash accounts before it is spent.
+// Outflows spend the oldest money first and the age of money after each outflow
+// is the average age of the money spent by the last 10 outflows.
+// Money spent before any was received is not counted.
+func AgeOfMoney(flows []CashFlow) []AgeOfMoneyPoint {
+	sorted := make([]CashFlow, len(flows))
+	copy(sorted, flows)
+	// Money received on a day can be spent on the same day
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Date.Equal(sorted[j].Date) {
+			return sorted[i].Quantity > 0 && sorted[j].Quantity <= 0
+		}
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	var received []CashFlow
+	var recent []float64
+	points := make([]AgeOfMoneyPoint, 0, len(sorted))
+
+	for _, flow := range sorted {
+		if flow.Quantity > 0 {
+			received = append(received, flow)
+			continue
+		}
+
+		// Spend the oldest money first
+		remaining, spent := -flow.Quantity, int64(0)
+		days := 0.0
+		for remaining > 0 && len(received) > 0 {
+			taken := received[0].Quantity
+			if taken > remaining {
+				taken = remaining
+			}
+			days += float64(taken) * flow.Date.Sub(received[0].Date).Hours() / 24
+			spent += taken
+			remaining -= taken
+
+			received[0].Quantity -= taken
+			if received[0].Quantity == 0 {
+				received = received[1:]
+			}
+		}
+		if spent == 0 {
+			continue
+		}
+
+		recent = append(recent, days/float64(spent))
+		if len(recent) > ageOfMoneyOutflows {
+			recent = recent[1:]
+		}
+
+		sum := 0.0
+		for _, age := range recent {
+			sum += age
+		}
+		points = append(points, AgeOfMoneyPoint{Date: flow.Date, Days: sum / float64(len(recent))})
+	}
+
+	return points
+}
@@ -0,0 +1,72 @@
+package journal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCashFlowOf(t *testing.T) {
+	transaction := NewTransaction()
+	for _, p := range []struct {
+		path     string
+		quantity int64
+	}{{"Assets:Savings", 10000}, {"Assets:Current", -12000}, {"Expenses:Groceries", 2000}} {
+		posting := NewPosting()
+		posting.AccountPath = p.path
+		posting.Amount = NewAmount("£", p.quantity)
+		transaction.AddPosting(posting)
+	}
+
+	// Only what leaves the cash accounts counts
+	if flow := CashFlowOf(&transaction, DefaultAccountRoles().Assets); flow.Quantity != -2000 {
+		t.Fatalf("expected a cash flow of -2000, got %d", flow.Quantity)
+	}
+}
+
+func TestAgeOfMoney(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, time.October, d, 0, 0, 0, 0, time.UTC) }
+
+	points := AgeOfMoney([]CashFlow{
+		// Out of order to check the flows are sorted
+		{Date: day(21), Quantity: -100000},
+		{Date: day(1), Quantity: 100000},
+		// Spent before any more money is received
+		{Date: day(2), Quantity: -50000},
+		{Date: day(11), Quantity: 100000},
+	})
+
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if points[0].Days != 1 {
+		t.Fatalf("expected the first outflow to be 1 day old, got %f", points[0].Days)
+	}
+	// The second outflow spends 500 which is 20 days old and 500 which is 10 days old
+	if math.Abs(points[1].Days-8) > 0.001 {
+		t.Fatalf("expected the age of money to average 8 days, got %f", points[1].Days)
+	}
+}
+
+func TestAgeOfMoneyAveragesLastTenOutflows(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	flows := []CashFlow{{Date: start, Quantity: 1000000}}
+
+	// The first outflow is 100 days old, the next ten are 200 days old
+	flows = append(flows, CashFlow{Date: start.AddDate(0, 0, 100), Quantity: -100})
+	for i := 0; i < 10; i++ {
+		flows = append(flows, CashFlow{Date: start.AddDate(0, 0, 200), Quantity: -100})
+	}
+
+	points := AgeOfMoney(flows)
+	if last := points[len(points)-1]; last.Days != 200 {
+		t.Fatalf("expected only the last 10 outflows to count, got %f days", last.Days)
+	}
+}
+
+func TestAgeOfMoneyWithoutIncome(t *testing.T) {
+	points := AgeOfMoney([]CashFlow{{Date: time.Now(), Quantity: -100}})
+	if len(points) != 0 {
+		t.Fatalf("spending without income should not have an age")
+	}
+}
@@ -1,27 +1,72 @@
 package journal
 
 import (
+	"sort"
 	"strings"
 	"time"
 )
 
 // Budget is a wrapper around accounts to enable monthly tracking
 type Budget struct {
-	Months map[time.Time]BudgetMonth // what was budgeted
+	Months    map[time.Time]BudgetMonth // what was budgeted
+	Goals     map[string][]Goal         // the goals set for each envelope, keyed by path
+	Commodity string                    // the commodity of the first posting added
+	Roles     AccountRoles              // which accounts are income, expenses, cash and credit
 }
 
 func NewBudget() Budget {
 	return Budget{
 		Months: make(map[time.Time]BudgetMonth, 12),
+		Goals:  make(map[string][]Goal),
+		Roles:  DefaultAccountRoles(),
+	}
+}
+
+// SortedMonths returns the months in the budget from earliest to latest
+func (b Budget) SortedMonths() []time.Time {
+	months := make([]time.Time, 0, len(b.Months))
+	for month := range b.Months {
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Before(months[j])
+	})
+	return months
+}
+
+// ExtendTo makes sure the budget has a month for every month up to and including the given one
+// so that what is carried over can be shown for months without any postings
+func (b *Budget) ExtendTo(month time.Time) {
+	month = NormaliseToMonth(month)
+	months := b.SortedMonths()
+
+	start := month
+	if len(months) > 0 {
+		start = months[len(months)-1]
+	}
+
+	for ; !start.After(month); start = start.AddDate(0, 1, 0) {
+		if _, found := b.Months[start]; !found {
+			b.Months[start] = newBudgetMonth()
+		}
 	}
 }
 
 type BudgetMonth struct {
-	EnvelopeRoot *Account
-	ExpenseRoot  *Account
-	Income       *Account
-	overspending Amount // flows from previous month
-	future       Amount // sum of accounts in future months' buckets
+	EnvelopeRoot *Account                // what was budgeted to each envelope this month
+	ExpenseRoot  *Account                // the activity in each envelope this month (negative for spending)
+	CarriedRoot  *Account                // what each envelope had available at the end of the previous month
+	Income       *Account                // income received this month
+	Goals        map[string]GoalProgress // progress towards each envelope's goal, keyed by path
+
+	// The summary of the month, set by Budget.Calculate
+	NotBudgeted        Amount // funds left unbudgeted at the end of the previous month (negative if overbudgeted)
+	Overspending       Amount // overspending from cash accounts in the previous month
+	CreditOverspending Amount // overspending on credit in the previous month, which becomes debt rather than using funds
+	Budgeted           Amount // the sum budgeted to envelopes this month
+	Future             Amount // funds from this month which are budgeted in future months
+	ToBeBudgeted       Amount // funds available to budget this month
+	Underfunded        Amount // what still needs budgeting this month to meet the envelopes' goals
 }
 
 func newBudgetMonth() BudgetMonth {
@@ -32,7 +77,9 @@ func newBudgetMonth() BudgetMonth {
 	return BudgetMonth{
 		EnvelopeRoot: NewAccount(BudgetRootID),
 		ExpenseRoot:  expenses,
+		CarriedRoot:  NewAccount(BudgetRootID),
 		Income:       income,
+		Goals:        make(map[string]GoalProgress),
 	}
 }
 
@@ -42,21 +89,32 @@ const (
 	EnvelopePosting PostingType = iota
 	ExpensePosting
 	IncomePosting
+	PaymentPosting // a payment from a cash account to a credit card
 )
 
 func (b *Budget) AddPosting(p *Posting, pt PostingType) error {
+	if b.Commodity == "" {
+		b.Commodity = p.Amount.Commodity
+	}
+
 	if pt == IncomePosting {
 		return b.addIncomePosting(p)
 	}
 
+	// Don't add the BudgetRoot to the budget
+	// What is left to be budgeted is worked out when the budget is calculated
+	if pt == EnvelopePosting && p.AccountPath == BudgetRootID {
+		return nil
+	}
+
 	// Get the month
-	bm, found := b.Months[normaliseToMonth(p.Transaction.Date)]
+	bm, found := b.Months[NormaliseToMonth(p.Transaction.Date)]
 	if !found {
 		bm = newBudgetMonth()
 	}
 
 	defer func() {
-		b.Months[normaliseToMonth(p.Transaction.Date)] = bm
+		b.Months[NormaliseToMonth(p.Transaction.Date)] = bm
 	}()
 
 	// wireUpPosting
@@ -64,26 +122,23 @@ func (b *Budget) AddPosting(p *Posting, pt PostingType) error {
 		panic("p.Account != nil")
 	}
 
-	// Don't add the BudgetRoot to the budget
-	// Instead, roll it in to the EnvelopeRoot
-	if pt == EnvelopePosting && p.AccountPath == BudgetRootID {
-		bm.EnvelopeRoot.Amount.Add(*p.Amount)
-		return nil
-	}
-
 	// Choose root
 	var root *Account
 	switch pt {
-	case ExpensePosting:
+	case ExpensePosting, PaymentPosting:
 		root = bm.ExpenseRoot
 	case EnvelopePosting:
 		root = bm.EnvelopeRoot
 	}
 
 	pathComponents := strings.Split(p.AccountPath, ":")
-	if pt == ExpensePosting {
-		// strip 'Expenses' from the path components
-		pathComponents = strings.Split(p.AccountPath, ":")[1:]
+	switch pt {
+	case ExpensePosting:
+		// strip the expenses root from the path components
+		pathComponents = b.Roles.Expenses.CategoryOrPath(p.AccountPath)
+	case PaymentPosting:
+		// payments come out of the card's payment envelope
+		pathComponents = b.paymentEnvelope(p.AccountPath)
 	}
 
 	// Assign an account to the posting
@@ -111,6 +166,9 @@ func (b *Budget) AddPosting(p *Posting, pt PostingType) error {
 
 		// Add the posting's amount to the envelope account and all of its ancestors
 		if err := envelopeAccount.WalkAncestors(func(a *Account) error {
+			if a.Amount.Commodity == "" {
+				a.Amount.Commodity = p.Amount.Commodity
+			}
 			if err := a.Amount.Add(*p.Amount); err != nil {
 				return err
 			}
@@ -120,7 +178,7 @@ func (b *Budget) AddPosting(p *Posting, pt PostingType) error {
 		}
 	}
 
-	if pt == ExpensePosting {
+	if pt == ExpensePosting || pt == PaymentPosting {
 		// Add to the expense account
 		expenseAccount.Postings = append(expenseAccount.Postings, p)
 
@@ -138,6 +196,9 @@ func (b *Budget) AddPosting(p *Posting, pt PostingType) error {
 
 		// Subtract the postings amount from the expense account and all of its ancestors
 		if err := expenseAccount.WalkAncestors(func(a *Account) error {
+			if a.Amount.Commodity == "" {
+				a.Amount.Commodity = p.Amount.Commodity
+			}
 			if err := a.Amount.Subtract(*p.Amount); err != nil {
 				return err
 			}
@@ -159,7 +220,7 @@ func (b *Budget) addExpensePosting(p *Posting) error {
 }
 
 func (b *Budget) addIncomePosting(p *Posting) error {
-	bm, found := b.Months[normaliseToMonth(p.Transaction.Date)]
+	bm, found := b.Months[NormaliseToMonth(p.Transaction.Date)]
 	if !found {
 		bm = newBudgetMonth()
 	}
@@ -167,9 +228,6 @@ func (b *Budget) addIncomePosting(p *Posting) error {
 	if bm.Income.Amount.Commodity == "" {
 		bm.Income.Amount.Commodity = p.Amount.Commodity
 	}
-	if bm.EnvelopeRoot.Amount.Commodity == "" {
-		bm.EnvelopeRoot.Amount.Commodity = p.Amount.Commodity
-	}
 
 	bm.Income.Postings = append(bm.Income.Postings, p)
 
@@ -178,14 +236,290 @@ func (b *Budget) addIncomePosting(p *Posting) error {
 		return err
 	}
 
-	if err := bm.EnvelopeRoot.Amount.Subtract(*p.Amount); err != nil {
-		return err
+	b.Months[NormaliseToMonth(p.Transaction.Date)] = bm
+	return nil
+}
+
+// Calculate works out the summary of each month once all postings have been added.
+// Months without postings between the first and last month are added so that funds flow through them.
+//
+// Funds which are not budgeted in a month roll over to the next month.
+// So too does what is available in each envelope, unless the envelope was overspent.
+// Overspent envelopes start the next month empty:
+// overspending from cash accounts is taken from the next month's funds
+// whereas overspending on credit becomes debt on the card instead.
+// Spending on a credit card which an envelope can cover moves those funds to the card's payment envelope,
+// which payments to the card then drain.
+// Money budgeted in a month which is not covered by the funds available up to that month
+// is taken from earlier months, reducing what they have to be budgeted.
+func (b *Budget) Calculate() error {
+	months := b.SortedMonths()
+	if len(months) == 0 {
+		return nil
+	}
+
+	// Fill any gaps between the first and last month
+	for month := months[0]; month.Before(months[len(months)-1]); month = month.AddDate(0, 1, 0) {
+		if _, found := b.Months[month]; !found {
+			b.Months[month] = newBudgetMonth()
+		}
+	}
+	months = b.SortedMonths()
+
+	// Work out the unbudgeted funds at the end of each month
+	leftovers := make([]int64, len(months))
+	var notBudgeted, overspending, creditOverspending int64
+	var carried []envelopeBalance
+	for i, month := range months {
+		bm := b.Months[month]
+
+		for _, c := range carried {
+			if _, err := bm.CarriedRoot.addToAccount(c.components, Amount{Commodity: b.Commodity, Quantity: c.quantity}); err != nil {
+				return err
+			}
+		}
+		if err := bm.fundCreditSpending(*b); err != nil {
+			return err
+		}
+		// Envelopes with goals are shown even when nothing has been budgeted to them
+		for path := range b.Goals {
+			if _, found := b.goalFor(path, month); found {
+				if _, err := bm.EnvelopeRoot.addToAccount(strings.Split(path, ":"), Amount{Commodity: b.Commodity}); err != nil {
+					return err
+				}
+			}
+		}
+		if err := bm.mirrorEnvelopes(b.Commodity); err != nil {
+			return err
+		}
+		bm.Underfunded = Amount{Commodity: b.Commodity, Quantity: bm.trackGoals(*b, month)}
+
+		bm.NotBudgeted = Amount{Commodity: b.Commodity, Quantity: notBudgeted}
+		bm.Overspending = Amount{Commodity: b.Commodity, Quantity: overspending}
+		bm.CreditOverspending = Amount{Commodity: b.Commodity, Quantity: creditOverspending}
+		bm.Budgeted = Amount{Commodity: b.Commodity, Quantity: bm.EnvelopeRoot.Amount.Quantity}
+
+		leftovers[i] = notBudgeted - overspending + bm.Income.Amount.Quantity - bm.Budgeted.Quantity
+		notBudgeted = leftovers[i]
+		carried, overspending, creditOverspending = bm.closeEnvelopes(b.Roles.Liabilities)
+
+		b.Months[month] = bm
+	}
+
+	// Reserve funds for future months which budget more than they receive.
+	// Overspending is left out as it only reduces the funds of the month after it happens.
+	for i, month := range months {
+		bm := b.Months[month]
+
+		future, net := int64(0), int64(0)
+		for _, later := range months[i+1:] {
+			lm := b.Months[later]
+			net += lm.Budgeted.Quantity - lm.Income.Amount.Quantity
+			if net > future {
+				future = net
+			}
+		}
+
+		// We can only cover the future with what this month has left
+		if future > leftovers[i] {
+			future = leftovers[i]
+		}
+		if future < 0 {
+			future = 0
+		}
+
+		bm.Future = Amount{Commodity: b.Commodity, Quantity: future}
+		bm.ToBeBudgeted = Amount{Commodity: b.Commodity, Quantity: leftovers[i] - future}
+
+		b.Months[month] = bm
 	}
 
-	b.Months[normaliseToMonth(p.Transaction.Date)] = bm
 	return nil
 }
 
-func normaliseToMonth(date time.Time) time.Time {
-	return date.AddDate(0, 0, -(date.Day() - 1))
+// Allocations returns what was budgeted to each envelope this month (excluding its descendants), keyed by path
+func (bm BudgetMonth) Allocations() map[string]int64 {
+	return ownAmounts(bm.EnvelopeRoot, 1)
+}
+
+// Spending returns what was spent from each envelope this month (excluding its descendants), keyed by path.
+// Payments to credit cards are not counted as spending.
+func (bm BudgetMonth) Spending() map[string]int64 {
+	spending := ownAmounts(bm.ExpenseRoot, -1)
+	for path := range spending {
+		if strings.Split(path, ":")[0] == CreditCardPaymentsID {
+			delete(spending, path)
+		}
+	}
+	return spending
+}
+
+// ownAmounts collects the non-zero amounts of the root's descendants (excluding their own descendants) multiplied by sign
+func ownAmounts(root *Account, sign int64) map[string]int64 {
+	amounts := make(map[string]int64)
+	root.walk(func(a *Account) {
+		if a == root {
+			return
+		}
+		if amount := a.ownAmount(); amount != 0 {
+			amounts[strings.Join(a.PathComponents, ":")] = sign * amount
+		}
+	})
+	return amounts
+}
+
+// envelopeBalance is what an envelope (excluding its descendants) has available
+type envelopeBalance struct {
+	components []string
+	quantity   int64
+}
+
+// mirrorEnvelopes makes sure every envelope exists in each of the month's envelope trees
+func (bm BudgetMonth) mirrorEnvelopes(commodity string) error {
+	var err error
+	roots := []*Account{bm.EnvelopeRoot, bm.ExpenseRoot, bm.CarriedRoot}
+	for _, root := range roots {
+		root.walk(func(a *Account) {
+			if a == root || err != nil {
+				return
+			}
+			for _, other := range roots {
+				if other != root && err == nil {
+					_, err = other.addToAccount(a.PathComponents, Amount{Commodity: commodity})
+				}
+			}
+		})
+	}
+	return err
+}
+
+// fundCreditSpending moves the funds covering each envelope's spending on credit cards
+// into the payment envelope for each card. Spending an envelope cannot cover is left as credit overspending.
+func (bm BudgetMonth) fundCreditSpending(b Budget) error {
+	type move struct {
+		components []string
+		quantity   int64
+	}
+	var moves []move
+
+	bm.ExpenseRoot.walk(func(expense *Account) {
+		if expense == bm.ExpenseRoot || len(expense.Postings) == 0 || expense.PathComponents[0] == CreditCardPaymentsID {
+			return
+		}
+
+		// What the envelope has before spending on credit
+		available := expense.ownAmount() + creditSpending(expense.Postings, b.Roles.Liabilities)
+		if e := bm.EnvelopeRoot.findAccount(expense.PathComponents); e != nil {
+			available += e.ownAmount()
+		}
+		if c := bm.CarriedRoot.findAccount(expense.PathComponents); c != nil {
+			available += c.ownAmount()
+		}
+
+		// Cover the spending in the order it happened
+		postings := make([]*Posting, len(expense.Postings))
+		copy(postings, expense.Postings)
+		sort.SliceStable(postings, func(i, j int) bool {
+			return postings[i].Transaction.Date.Before(postings[j].Transaction.Date)
+		})
+
+		for _, p := range postings {
+			card := creditCard(p, b.Roles.Liabilities)
+			if card == nil {
+				continue
+			}
+
+			funded := p.Amount.Quantity
+			// Refunds always go back to the envelope
+			if funded > 0 {
+				if available <= 0 {
+					continue
+				}
+				if funded > available {
+					funded = available
+				}
+			}
+			available -= funded
+
+			moves = append(moves, move{components: b.paymentEnvelope(card.AccountPath), quantity: funded})
+		}
+	})
+
+	for _, m := range moves {
+		if _, err := bm.ExpenseRoot.addToAccount(m.components, Amount{Commodity: b.Commodity, Quantity: m.quantity}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeEnvelopes works out what each envelope has available at the end of the month.
+// Returns what carries over to the next month and how much was overspent from cash and on credit.
+func (bm BudgetMonth) closeEnvelopes(liabilities AccountSet) (carried []envelopeBalance, cash, credit int64) {
+	bm.EnvelopeRoot.walk(func(envelope *Account) {
+		if envelope == bm.EnvelopeRoot {
+			return
+		}
+
+		available := envelope.ownAmount()
+		if c := bm.CarriedRoot.findAccount(envelope.PathComponents); c != nil {
+			available += c.ownAmount()
+		}
+		expense := bm.ExpenseRoot.findAccount(envelope.PathComponents)
+		if expense != nil {
+			available += expense.ownAmount()
+		}
+
+		if available >= 0 {
+			if available > 0 {
+				carried = append(carried, envelopeBalance{components: envelope.PathComponents, quantity: available})
+			}
+			return
+		}
+
+		// Overspending is on credit as far as the envelope's spending was on credit
+		overspent := -available
+		onCredit := int64(0)
+		if expense != nil {
+			onCredit = creditSpending(expense.Postings, liabilities)
+		}
+		if onCredit > overspent {
+			onCredit = overspent
+		}
+		cash += overspent - onCredit
+		credit += onCredit
+	})
+
+	return carried, cash, credit
+}
+
+// creditSpending sums the postings which were paid for with a liability account
+func creditSpending(postings []*Posting, liabilities AccountSet) int64 {
+	sum := int64(0)
+	for _, p := range postings {
+		if creditCard(p, liabilities) != nil {
+			sum += p.Amount.Quantity
+		}
+	}
+	return sum
+}
+
+// creditCard returns the liability posting which paid for the posting, or nil if it was not paid for on credit
+func creditCard(p *Posting, liabilities AccountSet) *Posting {
+	for _, other := range p.Transaction.Postings {
+		if other != p && liabilities.Matches(other.AccountPath) {
+			return other
+		}
+	}
+	return nil
+}
+
+// paymentEnvelope returns the path components of the payment envelope for a liability account
+func (b Budget) paymentEnvelope(liabilityPath string) []string {
+	return append([]string{CreditCardPaymentsID}, b.Roles.Liabilities.CategoryOrPath(liabilityPath)...)
+}
+
+// NormaliseToMonth returns midnight (UTC) on the first day of the date's month, which is how budget months are keyed
+func NormaliseToMonth(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
 }
@@ -0,0 +1,407 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+func newBudgetTestPosting(date time.Time, path string, quantity int64) *Posting {
+	t := NewTransaction()
+	t.Date = date
+	p := NewPosting()
+	p.Transaction = &t
+	p.AccountPath = path
+	p.Amount = NewAmount("£", quantity)
+	return p
+}
+
+func addBudgetTestPostings(t *testing.T, b *Budget, postings []*Posting, pt PostingType) {
+	for _, p := range postings {
+		if err := b.AddPosting(p, pt); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestBudgetMonthSummary(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	november := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Income:Job", -10000),
+		newBudgetTestPosting(november, "Income:Job", -10000),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Clothing", 5000),
+		newBudgetTestPosting(november, "Groceries", 4500),
+	}, EnvelopePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Expenses:Clothing", 4000),
+		newBudgetTestPosting(october, "Expenses:Groceries", 2000),
+	}, ExpensePosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      Amount
+		expected int64
+	}{
+		{"october budgeted", b.Months[october].Budgeted, 5000},
+		{"october to be budgeted", b.Months[october].ToBeBudgeted, 5000},
+		{"november not budgeted", b.Months[november].NotBudgeted, 5000},
+		{"november overspending", b.Months[november].Overspending, 2000},
+		{"november to be budgeted", b.Months[november].ToBeBudgeted, 8500},
+	}
+
+	for _, test := range tests {
+		if test.got.Quantity != test.expected {
+			t.Fatalf("%s: expected %d, got %d", test.name, test.expected, test.got.Quantity)
+		}
+	}
+}
+
+func TestBudgetedInFuture(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2020, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Income:Job", -10000),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Groceries", 2000),
+		newBudgetTestPosting(december, "Christmas", 6000),
+	}, EnvelopePosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The gap month should have been filled
+	if _, found := b.Months[time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)]; !found {
+		t.Fatalf("did not fill november")
+	}
+
+	if got := b.Months[october].Future.Quantity; got != 6000 {
+		t.Fatalf("october future: expected 6000, got %d", got)
+	}
+	if got := b.Months[october].ToBeBudgeted.Quantity; got != 2000 {
+		t.Fatalf("october to be budgeted: expected 2000, got %d", got)
+	}
+	if got := b.Months[december].ToBeBudgeted.Quantity; got != 2000 {
+		t.Fatalf("december to be budgeted: expected 2000, got %d", got)
+	}
+}
+
+func TestEnvelopesCarryOver(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	november := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Income:Job", -10000),
+		newBudgetTestPosting(november, "Income:Job", 0),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Clothing", 5000),
+		newBudgetTestPosting(october, "Fun", 1000),
+	}, EnvelopePosting)
+
+	// Spend on credit in Fun
+	cinema := newBudgetTestPosting(october, "Expenses:Fun", 2500)
+	card := newBudgetTestPosting(october, "Liabilities:Visa", -2500)
+	cinema.Transaction.Postings = []*Posting{cinema, card}
+	card.Transaction = cinema.Transaction
+
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Expenses:Clothing", 4000),
+		newBudgetTestPosting(october, "Expenses:Groceries", 2000),
+		cinema,
+	}, ExpensePosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	nov := b.Months[november]
+	clothing := nov.CarriedRoot.findAccount([]string{"Clothing"})
+	if clothing == nil || clothing.Amount.Quantity != 1000 {
+		t.Fatalf("did not carry clothing over")
+	}
+	if fun := nov.CarriedRoot.findAccount([]string{"Fun"}); fun != nil && fun.Amount.Quantity != 0 {
+		t.Fatalf("carried overspent fun: %d", fun.Amount.Quantity)
+	}
+	if nov.Overspending.Quantity != 2000 {
+		t.Fatalf("cash overspending: expected 2000, got %d", nov.Overspending.Quantity)
+	}
+	if nov.CreditOverspending.Quantity != 1500 {
+		t.Fatalf("credit overspending: expected 1500, got %d", nov.CreditOverspending.Quantity)
+	}
+	// 4000 not budgeted in October less 2000 overspent from cash
+	if nov.ToBeBudgeted.Quantity != 2000 {
+		t.Fatalf("to be budgeted: expected 2000, got %d", nov.ToBeBudgeted.Quantity)
+	}
+}
+
+func TestBudgetExtendTo(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Clothing", 5000),
+	}, EnvelopePosting)
+
+	b.ExtendTo(time.Date(2020, time.December, 15, 0, 0, 0, 0, time.Local))
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(b.Months) != 3 {
+		t.Fatalf("expected 3 months, got %d", len(b.Months))
+	}
+	december := b.Months[time.Date(2020, time.December, 1, 0, 0, 0, 0, time.UTC)]
+	if clothing := december.CarriedRoot.findAccount([]string{"Clothing"}); clothing == nil || clothing.Amount.Quantity != 5000 {
+		t.Fatalf("did not carry clothing through to december")
+	}
+}
+
+func TestCreditCardPaymentEnvelopes(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	november := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2020, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Income:Job", -10000),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Fun", 1000),
+	}, EnvelopePosting)
+
+	// Spend more on the card than Fun has
+	cinema := newBudgetTestPosting(october, "Expenses:Fun", 2500)
+	card := newBudgetTestPosting(october, "Liabilities:Visa", -2500)
+	cinema.Transaction.Postings = []*Posting{cinema, card}
+	card.Transaction = cinema.Transaction
+	addBudgetTestPostings(t, &b, []*Posting{cinema}, ExpensePosting)
+
+	// Pay some of the card off from a cash account
+	payment := newBudgetTestPosting(november, "Liabilities:Visa", 600)
+	current := newBudgetTestPosting(november, "Assets:Current", -600)
+	payment.Transaction.Postings = []*Posting{payment, current}
+	current.Transaction = payment.Transaction
+	addBudgetTestPostings(t, &b, []*Posting{payment}, PaymentPosting)
+
+	b.ExtendTo(december)
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	visa := []string{CreditCardPaymentsID, "Visa"}
+
+	// Only what Fun could cover moves to the payment envelope
+	if moved := b.Months[october].ExpenseRoot.findAccount(visa); moved == nil || moved.Amount.Quantity != 1000 {
+		t.Fatalf("did not move funded spending to the payment envelope")
+	}
+	if got := b.Months[november].CreditOverspending.Quantity; got != 1500 {
+		t.Fatalf("credit overspending: expected 1500, got %d", got)
+	}
+	// Credit overspending does not take from what is to be budgeted
+	if got := b.Months[november].ToBeBudgeted.Quantity; got != 9000 {
+		t.Fatalf("to be budgeted: expected 9000, got %d", got)
+	}
+
+	if carried := b.Months[november].CarriedRoot.findAccount(visa); carried == nil || carried.Amount.Quantity != 1000 {
+		t.Fatalf("did not carry the payment envelope over")
+	}
+	// The payment drains the envelope
+	if carried := b.Months[december].CarriedRoot.findAccount(visa); carried == nil || carried.Amount.Quantity != 400 {
+		t.Fatalf("payment did not drain the payment envelope")
+	}
+}
+
+func TestBudgetWithCustomRoles(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	b.Roles.Expenses = mustNewAccountSet("Spending")
+	b.Roles.Liabilities = mustNewAccountSet("Cards")
+
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Revenue:Job", -10000),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Food", 3000),
+	}, EnvelopePosting)
+
+	groceries := newBudgetTestPosting(october, "Spending:Food", 2000)
+	card := newBudgetTestPosting(october, "Cards:Visa", -2000)
+	groceries.Transaction.Postings = []*Posting{groceries, card}
+	card.Transaction = groceries.Transaction
+	addBudgetTestPostings(t, &b, []*Posting{groceries}, ExpensePosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if food := b.Months[october].ExpenseRoot.findAccount([]string{"Food"}); food == nil || food.Amount.Quantity != -2000 {
+		t.Fatalf("did not strip the expenses root from the envelope")
+	}
+	if visa := b.Months[october].ExpenseRoot.findAccount([]string{CreditCardPaymentsID, "Visa"}); visa == nil || visa.Amount.Quantity != 2000 {
+		t.Fatalf("did not move funded spending to the payment envelope")
+	}
+}
+
+func TestBudgetGoals(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	november := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+	december := time.Date(2020, time.December, 1, 0, 0, 0, 0, time.UTC)
+	january := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Income:Job", -100000),
+	}, IncomePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Christmas", 10000),
+		newBudgetTestPosting(october, "Groceries", 10000),
+		newBudgetTestPosting(october, "Emergency", 30000),
+	}, EnvelopePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Expenses:Emergency", 5000),
+	}, ExpensePosting)
+
+	b.AddGoal("Christmas", october, Goal{Type: TargetDateGoal, Target: Amount{Commodity: "£", Quantity: 60000}, By: december})
+	b.AddGoal("Groceries", october, Goal{Type: MonthlyGoal, Target: Amount{Commodity: "£", Quantity: 15000}})
+	b.AddGoal("Emergency", october, Goal{Type: BalanceGoal, Target: Amount{Commodity: "£", Quantity: 100000}})
+	// A goal set later does not apply to earlier months
+	b.AddGoal("Holiday", november, Goal{Type: MonthlyGoal, Target: Amount{Commodity: "£", Quantity: 5000}})
+
+	b.ExtendTo(january)
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		month    time.Time
+		path     string
+		expected int64
+	}{
+		// 600 over three months, less the 100 budgeted
+		{october, "Christmas", 10000},
+		{october, "Groceries", 5000},
+		// Spending from the envelope counts against a balance goal
+		{october, "Emergency", 75000},
+		// The 500 left is spread over the remaining two months
+		{november, "Christmas", 25000},
+		{november, "Groceries", 15000},
+		{november, "Holiday", 5000},
+	}
+
+	for _, test := range tests {
+		gp, found := b.Months[test.month].Goals[test.path]
+		if !found {
+			t.Fatalf("%s has no goal in %s", test.path, test.month.Format("2006-01"))
+		}
+		if gp.Needed.Quantity != test.expected {
+			t.Fatalf("%s needed in %s: expected %d, got %d", test.path, test.month.Format("2006-01"), test.expected, gp.Needed.Quantity)
+		}
+	}
+
+	if _, found := b.Months[october].Goals["Holiday"]; found {
+		t.Fatalf("applied a goal before it was set")
+	}
+
+	// Goals with a target date stop applying after their month
+	if gp, found := b.Months[december].Goals["Christmas"]; !found || gp.Needed.Quantity != 50000 {
+		t.Fatalf("expected the Christmas goal to need 50000 in its month, got %v", gp.Needed)
+	}
+	if _, found := b.Months[january].Goals["Christmas"]; found {
+		t.Fatalf("applied a goal after its target date")
+	}
+	if got := b.Months[october].Underfunded.Quantity; got != 90000 {
+		t.Fatalf("underfunded: expected 90000, got %d", got)
+	}
+	// The holiday envelope is shown once its goal is set
+	if b.Months[november].EnvelopeRoot.findAccount([]string{"Holiday"}) == nil {
+		t.Fatalf("did not create an envelope for the holiday goal")
+	}
+}
+
+func TestBudgetMonthAllocationsAndSpending(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Fun", 1000),
+		newBudgetTestPosting(october, "Fun:Hobbies", 500),
+	}, EnvelopePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Expenses:Fun:Hobbies", 300),
+		newBudgetTestPosting(october, "Expenses:Groceries", 2000),
+	}, ExpensePosting)
+	addBudgetTestPostings(t, &b, []*Posting{
+		newBudgetTestPosting(october, "Liabilities:Visa", 700),
+	}, PaymentPosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bm := b.Months[october]
+
+	allocations := bm.Allocations()
+	if len(allocations) != 2 || allocations["Fun"] != 1000 || allocations["Fun:Hobbies"] != 500 {
+		t.Fatalf("unexpected allocations: %v", allocations)
+	}
+
+	// Card payments are not spending
+	spending := bm.Spending()
+	if len(spending) != 2 || spending["Fun:Hobbies"] != 300 || spending["Groceries"] != 2000 {
+		t.Fatalf("unexpected spending: %v", spending)
+	}
+}
+
+func TestBudgetEnvelopesForWholeAccounts(t *testing.T) {
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	b := NewBudget()
+	expenses, err := NewAccountSet("Bills", "/^Services:.*/", "/^(Bills|Subscriptions)/", "/^Expenses:(Home|Office):Storage$/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b.Roles.Expenses = expenses
+
+	addBudgetTestPostings(t, &b, []*Posting{
+		// The root itself
+		newBudgetTestPosting(october, "Bills", 1000),
+		// Regular expressions which match the whole path
+		newBudgetTestPosting(october, "Subscriptions", 200),
+		newBudgetTestPosting(october, "Services:Cloud:Storage", 500),
+		// Accounts which only differ before the end of the match
+		newBudgetTestPosting(october, "Expenses:Home:Storage", 300),
+		newBudgetTestPosting(october, "Expenses:Office:Storage", 400),
+	}, ExpensePosting)
+
+	if err := b.Calculate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if bills := b.Months[october].ExpenseRoot.findAccount([]string{"Bills"}); bills == nil || bills.Amount.Quantity != -1000 {
+		t.Fatalf("a posting to the root was not budgeted under its name")
+	}
+	if subscriptions := b.Months[october].ExpenseRoot.findAccount([]string{"Subscriptions"}); subscriptions == nil || subscriptions.Amount.Quantity != -200 {
+		t.Fatalf("a posting matched to the end of its path was not budgeted under its name")
+	}
+	if storage := b.Months[october].ExpenseRoot.findAccount([]string{"Services", "Cloud", "Storage"}); storage == nil || storage.Amount.Quantity != -500 {
+		t.Fatalf("a posting matched to the end of its path was not budgeted under its whole path")
+	}
+	home := b.Months[october].ExpenseRoot.findAccount([]string{"Expenses", "Home", "Storage"})
+	office := b.Months[october].ExpenseRoot.findAccount([]string{"Expenses", "Office", "Storage"})
+	if home == nil || home.Amount.Quantity != -300 || office == nil || office.Amount.Quantity != -400 {
+		t.Fatalf("postings to different accounts were budgeted in the same envelope")
+	}
+}
@@ -0,0 +1,299 @@
+package journal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Check is a check which can be run over a journal
+type Check string
+
+// The checks which can be run over a journal.
+// Transactions are always checked to balance when they are parsed.
+const (
+	AssertionsCheck  Check = "assertions"  // balance assertions hold
+	AccountsCheck    Check = "accounts"    // accounts are declared
+	CommoditiesCheck Check = "commodities" // commodities are declared
+	PayeesCheck      Check = "payees"      // payees are declared
+	OrderedCheck     Check = "ordered"     // dates are in order within each file
+	DuplicatesCheck  Check = "duplicates"  // no transaction appears twice
+	TagsCheck        Check = "tags"        // tags are declared or allowed
+)
+
+// Checks lists every check in the order they are run
+var Checks = []Check{AssertionsCheck, AccountsCheck, CommoditiesCheck, PayeesCheck, OrderedCheck, DuplicatesCheck, TagsCheck}
+
+// Problem is something wrong with a journal found by a check
+type Problem struct {
+	Check   Check
+	Path    string
+	Line    int
+	Message string
+	Hint    string
+}
+
+// checkedTransaction is a transaction along with the file it was found in
+type checkedTransaction struct {
+	transaction *Transaction
+	path        string
+}
+
+// Checker runs checks over the transactions and declarations of a journal
+type Checker struct {
+	checks       map[Check]bool
+	declared     map[DeclarationType]map[string]bool
+	transactions []checkedTransaction
+}
+
+// NewChecker creates a Checker which runs the given checks
+func NewChecker(checks ...Check) Checker {
+	c := Checker{
+		checks:       make(map[Check]bool, len(checks)),
+		declared:     make(map[DeclarationType]map[string]bool, 4),
+		transactions: make([]checkedTransaction, 0, 256),
+	}
+	for _, check := range checks {
+		c.checks[check] = true
+	}
+	return c
+}
+
+// AllowTags allows tags without them being declared
+func (c *Checker) AllowTags(tags ...string) {
+	for _, tag := range tags {
+		c.Declare(Declaration{Type: TagDeclaration, Name: tag})
+	}
+}
+
+// Declare records a declaration
+func (c *Checker) Declare(d Declaration) {
+	if c.declared[d.Type] == nil {
+		c.declared[d.Type] = make(map[string]bool)
+	}
+	c.declared[d.Type][d.Name] = true
+}
+
+// AddTransaction adds a transaction to be checked.
+// Transactions should be added in the order they are found in their file.
+func (c *Checker) AddTransaction(t *Transaction, path string) error {
+	c.transactions = append(c.transactions, checkedTransaction{transaction: t, path: path})
+	return nil
+}
+
+// Run runs the checks, returning the problems found ordered by file and line
+func (c Checker) Run() []Problem {
+	problems := make([]Problem, 0)
+
+	for _, check := range Checks {
+		if !c.checks[check] {
+			continue
+		}
+		switch check {
+		case AssertionsCheck:
+			problems = append(problems, c.checkAssertions()...)
+		case AccountsCheck:
+			problems = append(problems, c.checkDeclared(check, AccountDeclaration, "account", func(p *Posting) string {
+				return p.AccountPath
+			})...)
+		case CommoditiesCheck:
+			problems = append(problems, c.checkDeclared(check, CommodityDeclaration, "commodity", func(p *Posting) string {
+				if p.Amount == nil {
+					return ""
+				}
+				return strings.TrimSpace(p.Amount.Commodity)
+			})...)
+		case PayeesCheck:
+			problems = append(problems, c.checkPayees()...)
+		case OrderedCheck:
+			problems = append(problems, c.checkOrdered()...)
+		case DuplicatesCheck:
+			problems = append(problems, c.checkDuplicates()...)
+		case TagsCheck:
+			problems = append(problems, c.checkTags()...)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// checkAssertions works out each account's balance in date order and compares it with what the postings assert.
+// Assertions are about the account's own postings (not its subaccounts) in the commodity asserted.
+func (c Checker) checkAssertions() []Problem {
+	sorted := make([]checkedTransaction, len(c.transactions))
+	copy(sorted, c.transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].transaction.Date.Before(sorted[j].transaction.Date)
+	})
+
+	problems := make([]Problem, 0)
+	balances := make(map[string]map[string]int64)
+	for _, ct := range sorted {
+		for _, p := range ct.transaction.Postings {
+			if balances[p.AccountPath] == nil {
+				balances[p.AccountPath] = make(map[string]int64)
+			}
+			if p.Amount != nil {
+				balances[p.AccountPath][strings.TrimSpace(p.Amount.Commodity)] += p.Amount.Quantity
+			}
+
+			if p.Assertion == nil {
+				continue
+			}
+			commodity := strings.TrimSpace(p.Assertion.Commodity)
+			actual := Amount{Commodity: p.Assertion.Commodity, Quantity: balances[p.AccountPath][commodity]}
+			if actual.Quantity != p.Assertion.Quantity {
+				problems = append(problems, Problem{
+					Check:   AssertionsCheck,
+					Path:    ct.path,
+					Line:    p.Line,
+					Message: fmt.Sprintf("balance assertion failed: %s is %s not %s", p.AccountPath, actual.DisplayableQuantity(true), p.Assertion.DisplayableQuantity(true)),
+					Hint:    fmt.Sprintf("the difference is %s", Amount{Commodity: p.Assertion.Commodity, Quantity: p.Assertion.Quantity - actual.Quantity}.DisplayableQuantity(true)),
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// checkDeclared reports the postings whose account or commodity (the name) was not declared
+func (c Checker) checkDeclared(check Check, dt DeclarationType, kind string, name func(p *Posting) string) []Problem {
+	problems := make([]Problem, 0)
+	reported := make(map[string]bool)
+	for _, ct := range c.transactions {
+		for _, p := range ct.transaction.Postings {
+			n := name(p)
+			if len(n) == 0 || c.declared[dt][n] || reported[n] {
+				continue
+			}
+			// Only the first use is reported
+			reported[n] = true
+			problems = append(problems, Problem{
+				Check:   check,
+				Path:    ct.path,
+				Line:    p.Line,
+				Message: fmt.Sprintf("%s %s has not been declared", kind, n),
+				Hint:    fmt.Sprintf("declare it with '%s %s'", kind, n),
+			})
+		}
+	}
+	return problems
+}
+
+func (c Checker) checkPayees() []Problem {
+	problems := make([]Problem, 0)
+	reported := make(map[string]bool)
+	for _, ct := range c.transactions {
+		payee := ct.transaction.Payee
+		if len(payee) == 0 || c.declared[PayeeDeclaration][payee] || reported[payee] {
+			continue
+		}
+		reported[payee] = true
+		problems = append(problems, Problem{
+			Check:   PayeesCheck,
+			Path:    ct.path,
+			Line:    ct.transaction.Line,
+			Message: fmt.Sprintf("payee %s has not been declared", payee),
+			Hint:    fmt.Sprintf("declare it with 'payee %s'", payee),
+		})
+	}
+	return problems
+}
+
+// checkOrdered reports transactions dated before the transaction above them in the same file
+func (c Checker) checkOrdered() []Problem {
+	problems := make([]Problem, 0)
+	previous := make(map[string]*Transaction)
+	for _, ct := range c.transactions {
+		if p := previous[ct.path]; p != nil && ct.transaction.Date.Before(p.Date) {
+			problems = append(problems, Problem{
+				Check:   OrderedCheck,
+				Path:    ct.path,
+				Line:    ct.transaction.Line,
+				Message: fmt.Sprintf("transaction dated %s comes after one dated %s", ct.transaction.Date.Format("2006-01-02"), p.Date.Format("2006-01-02")),
+				Hint:    fmt.Sprintf("move it above the transaction on line %d", p.Line),
+			})
+			continue
+		}
+		previous[ct.path] = ct.transaction
+	}
+	return problems
+}
+
+// checkDuplicates reports transactions with the same date, payee and postings as an earlier one
+func (c Checker) checkDuplicates() []Problem {
+	problems := make([]Problem, 0)
+	seen := make(map[string]checkedTransaction)
+	for _, ct := range c.transactions {
+		key := duplicateKey(ct.transaction)
+		if original, found := seen[key]; found {
+			problems = append(problems, Problem{
+				Check:   DuplicatesCheck,
+				Path:    ct.path,
+				Line:    ct.transaction.Line,
+				Message: fmt.Sprintf("transaction is a duplicate of the one at %s:%d", original.path, original.transaction.Line),
+				Hint:    "remove one of them, or tell them apart with a note",
+			})
+			continue
+		}
+		seen[key] = ct
+	}
+	return problems
+}
+
+// duplicateKey identifies a transaction by its date, payee, notes and postings
+func duplicateKey(t *Transaction) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%s|%s", t.Date.Format("2006-01-02"), t.Payee, t.HeaderNote, strings.Join(t.Notes, "|"))
+
+	postings := make([]string, 0, len(t.Postings))
+	for _, p := range t.Postings {
+		amount := ""
+		if p.Amount != nil {
+			amount = fmt.Sprintf("%s%d", strings.TrimSpace(p.Amount.Commodity), p.Amount.Quantity)
+		}
+		postings = append(postings, p.AccountPath+"="+amount)
+	}
+	sort.Strings(postings)
+	b.WriteString("|" + strings.Join(postings, "|"))
+	return b.String()
+}
+
+// checkTags reports tags which have not been declared or allowed
+func (c Checker) checkTags() []Problem {
+	problems := make([]Problem, 0)
+	check := func(path string, line int, comment string) {
+		for _, tag := range TagsIn(comment) {
+			if c.declared[TagDeclaration][tag] {
+				continue
+			}
+			problems = append(problems, Problem{
+				Check:   TagsCheck,
+				Path:    path,
+				Line:    line,
+				Message: fmt.Sprintf("tag %s is not allowed", tag),
+				Hint:    fmt.Sprintf("declare it with 'tag %s'", tag),
+			})
+		}
+	}
+
+	for _, ct := range c.transactions {
+		t := ct.transaction
+		check(ct.path, t.Line, t.HeaderNote)
+		for _, note := range t.Notes {
+			check(ct.path, t.Line, note)
+		}
+		for _, p := range t.Postings {
+			for _, comment := range p.Comments {
+				check(ct.path, p.Line, comment)
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,126 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+// newCheckTestTransaction creates a transaction starting on line with postings on the lines after it
+func newCheckTestTransaction(date time.Time, payee string, line int, postings ...*Posting) *Transaction {
+	t := NewTransaction()
+	t.Date = date
+	t.Payee = payee
+	t.Line = line
+	for i, p := range postings {
+		p.Transaction = &t
+		p.Line = line + i + 1
+		t.AddPosting(p)
+	}
+	t.Close()
+	return &t
+}
+
+func newCheckTestPosting(account string, amount *Amount) *Posting {
+	p := NewPosting()
+	p.AccountPath = account
+	p.Amount = amount
+	return p
+}
+
+func TestCheckAssertions(t *testing.T) {
+	first := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+
+	pay := newCheckTestPosting("Assets:Current", NewAmount("£", 200000))
+	pay.Assertion = NewAmount("£", 200000)
+	shop := newCheckTestPosting("Assets:Current", nil)
+	shop.Assertion = NewAmount("£", 100000)
+
+	c := NewChecker(AssertionsCheck)
+	// The shop comes first in the file but is checked after the pay
+	c.AddTransaction(newCheckTestTransaction(second, "Shop", 1, newCheckTestPosting("Expenses:Food", NewAmount("£", 1000)), shop), "test.journal")
+	c.AddTransaction(newCheckTestTransaction(first, "Pay", 5, newCheckTestPosting("Income:Job", NewAmount("£", -200000)), pay), "test.journal")
+
+	problems := c.Run()
+	if len(problems) != 1 {
+		t.Fatalf("expected 1 problem, got %v", problems)
+	}
+	if problems[0].Line != 3 || problems[0].Message != "balance assertion failed: Assets:Current is £1990.00 not £1000.00" {
+		t.Fatalf("unexpected problem: %+v", problems[0])
+	}
+}
+
+func TestCheckDeclarations(t *testing.T) {
+	date := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewChecker(AccountsCheck, CommoditiesCheck, PayeesCheck, TagsCheck)
+	c.Declare(Declaration{Type: AccountDeclaration, Name: "Expenses:Food"})
+	c.Declare(Declaration{Type: AccountDeclaration, Name: "Assets:Current"})
+	c.Declare(Declaration{Type: CommodityDeclaration, Name: "£"})
+	c.Declare(Declaration{Type: PayeeDeclaration, Name: "Shop"})
+	c.AllowTags("trip")
+
+	food := newCheckTestPosting("Expenses:Food", NewAmount("£", 1000))
+	food.AddComment(":trip:")
+	c.AddTransaction(newCheckTestTransaction(date, "Shop", 1, food, newCheckTestPosting("Assets:Current", nil)), "test.journal")
+	if problems := c.Run(); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	dining := newCheckTestPosting("Expenses:Dining", NewAmount("$", 1000))
+	dining.AddComment("work: yes")
+	c.AddTransaction(newCheckTestTransaction(date, "Cafe", 4, dining, newCheckTestPosting("Assets:Current", nil)), "test.journal")
+
+	expected := []struct {
+		check Check
+		line  int
+	}{{PayeesCheck, 4}, {AccountsCheck, 5}, {CommoditiesCheck, 5}, {TagsCheck, 5}}
+	problems := c.Run()
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), problems)
+	}
+	for i, e := range expected {
+		if problems[i].Check != e.check || problems[i].Line != e.line {
+			t.Errorf("problem %d: expected %s on line %d, got %+v", i, e.check, e.line, problems[i])
+		}
+	}
+}
+
+func TestCheckOrderedAndDuplicates(t *testing.T) {
+	first := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+
+	shop := func(date time.Time, line int) *Transaction {
+		return newCheckTestTransaction(date, "Shop", line, newCheckTestPosting("Expenses:Food", NewAmount("£", 1000)), newCheckTestPosting("Assets:Current", nil))
+	}
+
+	c := NewChecker(OrderedCheck, DuplicatesCheck)
+	c.AddTransaction(shop(second, 1), "a.journal")
+	c.AddTransaction(shop(first, 5), "a.journal")
+	// Files are ordered separately
+	c.AddTransaction(shop(first, 1), "b.journal")
+
+	problems := c.Run()
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if problems[0].Check != OrderedCheck || problems[0].Path != "a.journal" || problems[0].Line != 5 {
+		t.Fatalf("expected the second transaction in a.journal to be out of order, got %+v", problems[0])
+	}
+	if problems[1].Check != DuplicatesCheck || problems[1].Path != "b.journal" {
+		t.Fatalf("expected the transaction in b.journal to be a duplicate, got %+v", problems[1])
+	}
+}
+
+func TestTagsIn(t *testing.T) {
+	tags := TagsIn(":trip:work: goal: £600 not:a tag")
+	expected := []string{"trip", "work", "goal"}
+	if len(tags) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, tags)
+	}
+	for i := range expected {
+		if tags[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, tags)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package journal
+
+import "strings"
+
+// DeclarationType is what a declaration declares
+type DeclarationType int
+
+// Things which can be declared in a journal
+const (
+	AccountDeclaration DeclarationType = iota
+	CommodityDeclaration
+	PayeeDeclaration
+	TagDeclaration
+)
+
+// Declaration declares an account, commodity, payee or tag so that checks can tell it is expected
+type Declaration struct {
+	Type DeclarationType
+	Name string
+	Line int // the line of the journal file the declaration is on
+}
+
+// TagsIn finds the tags in a comment.
+// Tags are written between colons (:holiday:work:) or as the name of a value (goal: £600).
+func TagsIn(comment string) []string {
+	tags := make([]string, 0)
+	for _, word := range strings.Fields(comment) {
+		switch {
+		case len(word) > 2 && strings.HasPrefix(word, ":") && strings.HasSuffix(word, ":"):
+			for _, tag := range strings.Split(word[1:len(word)-1], ":") {
+				if len(tag) > 0 {
+					tags = append(tags, tag)
+				}
+			}
+		case len(word) > 1 && !strings.HasPrefix(word, ":") && strings.HasSuffix(word, ":"):
+			tags = append(tags, word[:len(word)-1])
+		}
+	}
+	return tags
+}
@@ -0,0 +1,136 @@
+package journal
+
+import (
+	"strings"
+	"time"
+)
+
+// GoalType describes what a goal asks of an envelope
+type GoalType int
+
+// GoalType values
+const (
+	BalanceGoal    GoalType = iota // keep an amount available in the envelope
+	TargetDateGoal                 // have an amount available by a month
+	MonthlyGoal                    // budget an amount every month
+)
+
+// Goal is a target for an envelope which applies from the month it was set in
+type Goal struct {
+	Type   GoalType
+	Target Amount
+	By     time.Time // the month a TargetDateGoal should be reached by
+	Start  time.Time // the month the goal was set in
+}
+
+// GoalProgress is how far an envelope is towards its goal in a month
+type GoalProgress struct {
+	Goal   Goal
+	Funded Amount // what counts towards the target
+	Needed Amount // what still needs budgeting this month to stay on track
+}
+
+// Progress returns the fraction of the target which has been funded, between 0 and 1
+func (gp GoalProgress) Progress() float64 {
+	if gp.Goal.Target.Quantity <= 0 {
+		return 1
+	}
+	progress := float64(gp.Funded.Quantity) / float64(gp.Goal.Target.Quantity)
+	if progress < 0 {
+		return 0
+	}
+	if progress > 1 {
+		return 1
+	}
+	return progress
+}
+
+// AddGoal sets a goal for the envelope at path from the given month onwards.
+// A goal replaces any set for the envelope in earlier months.
+func (b *Budget) AddGoal(path string, month time.Time, g Goal) {
+	g.Start = NormaliseToMonth(month)
+	if !g.By.IsZero() {
+		g.By = NormaliseToMonth(g.By)
+	}
+	b.Goals[path] = append(b.Goals[path], g)
+}
+
+// goalFor returns the goal which applies to the envelope at path in the month.
+// Goals with a target date stop applying after their month, whether or not they were reached.
+func (b Budget) goalFor(path string, month time.Time) (Goal, bool) {
+	var goal Goal
+	found := false
+	for _, g := range b.Goals[path] {
+		if g.Start.After(month) {
+			continue
+		}
+		if !found || !g.Start.Before(goal.Start) {
+			goal = g
+			found = true
+		}
+	}
+	if found && goal.Type == TargetDateGoal && month.After(goal.By) {
+		return Goal{}, false
+	}
+	return goal, found
+}
+
+// trackGoals works out the progress of each envelope with a goal in the month.
+// Returns the total still needed to meet the goals.
+func (bm BudgetMonth) trackGoals(b Budget, month time.Time) int64 {
+	underfunded := int64(0)
+	for path := range b.Goals {
+		goal, found := b.goalFor(path, month)
+		if !found {
+			continue
+		}
+
+		components := strings.Split(path, ":")
+		budgeted, carried, activity := int64(0), int64(0), int64(0)
+		if e := bm.EnvelopeRoot.findAccount(components); e != nil {
+			budgeted = e.ownAmount()
+		}
+		if c := bm.CarriedRoot.findAccount(components); c != nil {
+			carried = c.ownAmount()
+		}
+		if a := bm.ExpenseRoot.findAccount(components); a != nil {
+			activity = a.ownAmount()
+		}
+		available := carried + budgeted + activity
+
+		var funded, needed int64
+		switch goal.Type {
+		case MonthlyGoal:
+			funded = budgeted
+			needed = goal.Target.Quantity - budgeted
+		case BalanceGoal:
+			funded = available
+			needed = goal.Target.Quantity - available
+		case TargetDateGoal:
+			// Spread what is left to save over the months until the goal is due
+			funded = available
+			remaining := int64(monthsBetween(month, goal.By)) + 1
+			toSave := goal.Target.Quantity - carried
+			if toSave > 0 {
+				toSave = (toSave + remaining - 1) / remaining
+			}
+			needed = toSave - budgeted
+		}
+		if needed < 0 {
+			needed = 0
+		}
+		underfunded += needed
+
+		bm.Goals[path] = GoalProgress{
+			Goal:   goal,
+			Funded: Amount{Commodity: goal.Target.Commodity, Quantity: funded},
+			Needed: Amount{Commodity: goal.Target.Commodity, Quantity: needed},
+		}
+	}
+	return underfunded
+}
+
+// monthsBetween counts the months from one month to a later one
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
@@ -2,16 +2,19 @@ package journal
 
 import (
 	"strings"
-	"time"
 )
 
 // Identifiers for accounts
 const (
 	RootID       string = "_root_"
 	BudgetRootID string = "_budget_root_"
-	// TODO allow these to be set by the user
-	ExpensesID string = "Expenses"
-	IncomeID   string = "Income"
+	// The default roots of the accounts with roles in the budget (see AccountRoles)
+	AssetsID      string = "Assets"
+	ExpensesID    string = "Expenses"
+	IncomeID      string = "Income"
+	LiabilitiesID string = "Liabilities"
+	// CreditCardPaymentsID is the budget category holding the funds set aside to pay off each credit card
+	CreditCardPaymentsID string = "Credit Card Payments"
 )
 
 // Journal holds information about the transactions parsed
@@ -20,6 +23,7 @@ type Journal struct {
 	periodicTransactions []*PeriodicTransaction
 	filePaths            []string
 	Root                 *Account
+	accounts             map[string]*Account // the accounts postings have been added to, by path
 }
 
 // NewJournal creates a Journal
@@ -29,6 +33,7 @@ func NewJournal() Journal {
 		periodicTransactions: make([]*PeriodicTransaction, 0, 256),
 		filePaths:            make([]string, 0, 10),
 		Root:                 NewAccount(RootID),
+		accounts:             make(map[string]*Account),
 	}
 
 	return j
@@ -40,40 +45,56 @@ func (j *Journal) AddTransaction(t *Transaction, locationHint string) {
 	j.filePaths = append(j.filePaths, locationHint)
 }
 
-// AddPeriodicTransaction adds a periodic transaction to the journal
+// AddPeriodicTransaction adds a periodic transaction to the journal.
+// Periodic transactions are not added to the accounts as they describe what is expected rather than what happened.
 func (j *Journal) AddPeriodicTransaction(pt *PeriodicTransaction, locationHint string) error {
 	j.periodicTransactions = append(j.periodicTransactions, pt)
-
-	if pt.Period.Interval == PNone {
-		// Handle budget allocations differently
-		// PeriodicTransaction with no interval are considered budget transactions
-		return nil
-	}
-
-	// Convert the periodic transaction to real transactions then link them
-	// TODO take time bounds for running periodic transactions
-	transactions := pt.Run(time.Time{}, time.Time{})
-	for _, t := range transactions {
-		j.AddTransaction(&t, locationHint)
-
-		for _, p := range t.Postings {
-			if err := j.AddPosting(p); err != nil {
-				return err
-			}
-		}
-	}
-
 	return nil
 }
 
+// PeriodicTransactions returns the periodic transactions added to the journal
+func (j Journal) PeriodicTransactions() []*PeriodicTransaction {
+	return j.periodicTransactions
+}
+
 // AddPosting handles adding normal transaction postings to the journal
 func (j *Journal) AddPosting(p *Posting) error {
+	// Finding an account by its path is quicker than splitting the path and walking the tree
+	if p.Account == nil && j.accounts != nil {
+		account, found := j.accounts[p.AccountPath]
+		if !found {
+			account = j.Root.FindOrCreateAccount(strings.Split(p.AccountPath, ":"))
+			j.accounts[p.AccountPath] = account
+		}
+		p.Account = account
+	}
+
 	if err := wireUpPosting(j.Root, p.Transaction, p); err != nil {
 		return err
 	}
 	return nil
 }
 
+// PruneAccounts removes accounts deeper than depth, keeping their amounts in their ancestors (see Account.PruneChildren)
+func (j *Journal) PruneAccounts(depth int) {
+	j.Root.PruneChildren(depth, 0)
+	j.forgetAccounts()
+}
+
+// RemoveEmptyAccounts removes accounts with a zero amount (see Account.RemoveEmptyChildren)
+func (j *Journal) RemoveEmptyAccounts() {
+	j.Root.RemoveEmptyChildren()
+	j.forgetAccounts()
+}
+
+// forgetAccounts empties the accounts found for earlier postings after the tree has changed
+// so that later postings are added to the accounts in the tree rather than ones which were removed
+func (j *Journal) forgetAccounts() {
+	for path := range j.accounts {
+		delete(j.accounts, path)
+	}
+}
+
 func wireUpPosting(root *Account, transaction *Transaction, p *Posting) error {
 	if p.Account == nil {
 		pathComponents := strings.Split(p.AccountPath, ":")
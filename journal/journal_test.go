@@ -0,0 +1,32 @@
+package journal
+
+import "testing"
+
+func TestPostingsAfterPruningAreAddedToTheTree(t *testing.T) {
+	j := NewJournal()
+	transaction := NewTransaction()
+	addPosting := func() *Posting {
+		p := NewPosting()
+		p.AccountPath = "Expenses:Fun:Hobbies"
+		p.Amount = NewAmount("£", 100)
+		p.Transaction = &transaction
+		if err := j.AddPosting(p); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return p
+	}
+
+	addPosting()
+	j.PruneAccounts(1)
+	p := addPosting()
+
+	// The posting's account must be reachable from the root rather than one which was pruned
+	for a := p.Account; a != j.Root; a = a.Parent {
+		if a.Parent == nil || a.Parent.Children[a.Name] != a {
+			t.Fatalf("posting was added to an account which is not in the tree")
+		}
+	}
+	if expenses := j.Root.Children["Expenses"]; expenses.Amount.Quantity != 200 {
+		t.Fatalf("expected expenses to total 200, got %d", expenses.Amount.Quantity)
+	}
+}
@@ -2,7 +2,6 @@
 package journal
 
 import (
-	"fmt"
 	"time"
 )
 
@@ -43,28 +42,96 @@ func NewPeriodicTransaction() PeriodicTransaction {
 	return PeriodicTransaction{}
 }
 
-// Run converts a single PeriodicTransaction into an array of Transactions for a given date span
-// Does not extend time bounds to match parameters
+// Run converts a single PeriodicTransaction into an array of Transactions for a given date span.
+// Transactions occur at the start of each of the period's intervals on or after start and before end.
+// Periods without a start date line their intervals up with the calendar (e.g. the first of the month).
+// Returns nothing if neither the period nor the span has an end.
 func (pt *PeriodicTransaction) Run(start, end time.Time) []Transaction {
 	if pt.Period.Interval == PNone {
 		pt.Transaction.Date = pt.Period.StartDate
 		return []Transaction{pt.Transaction}
 	}
 
-	// Check if this transaction is outside the time bounds
-	if (!start.IsZero() && start.After(pt.Period.EndDate)) ||
-		(!end.IsZero() && end.Before(pt.Period.StartDate)) {
+	// Sync provided bounds with this transaction's ones
+	if !pt.Period.EndDate.IsZero() && (end.IsZero() || end.After(pt.Period.EndDate)) {
+		end = pt.Period.EndDate
+	}
+	if end.IsZero() || (start.IsZero() && pt.Period.StartDate.IsZero()) {
 		return []Transaction{}
 	}
 
-	// Sync provided bounds with this transaction's ones
-	if !start.IsZero() && start.Before(pt.Period.StartDate) {
-		start = pt.Period.StartDate
+	date := pt.Period.StartDate
+	if date.IsZero() {
+		date = pt.Period.Align(start)
 	}
-	if !end.IsZero() && end.After(pt.Period.EndDate) {
-		end = pt.Period.EndDate
+
+	dates := make([]time.Time, 0, 12)
+	for ; date.Before(end); date = pt.Period.Next(date) {
+		if !date.Before(start) {
+			dates = append(dates, date)
+		}
+	}
+
+	transactions := make([]Transaction, len(dates))
+	for i, date := range dates {
+		transactions[i] = pt.Transaction
+		transactions[i].Date = date
+		transactions[i].Postings = make([]*Posting, 0, len(pt.Transaction.Postings))
+		for _, p := range pt.Transaction.Postings {
+			posting := *p
+			posting.Transaction = &transactions[i]
+			posting.Account = nil
+			transactions[i].Postings = append(transactions[i].Postings, &posting)
+		}
 	}
 
-	fmt.Println("unhandled conversion of non-budget periodic transaction")
-	return []Transaction{}
+	return transactions
+}
+
+// Next returns the date one interval after date
+func (p Period) Next(date time.Time) time.Time {
+	n := p.IntervalFrequency
+	if n < 1 {
+		n = 1
+	}
+
+	switch p.Interval {
+	case PDaily:
+		return date.AddDate(0, 0, n)
+	case PWeekly:
+		return date.AddDate(0, 0, 7*n)
+	case PBiweekly, PFortnightly:
+		return date.AddDate(0, 0, 14*n)
+	case PMonthly:
+		return date.AddDate(0, n, 0)
+	case PBiMonthly:
+		return date.AddDate(0, 2*n, 0)
+	case PQuarterly:
+		return date.AddDate(0, 3*n, 0)
+	case PYearly:
+		return date.AddDate(n, 0, 0)
+	default:
+		return date
+	}
+}
+
+// Align returns the start of the calendar interval (day, week, month, quarter or year) containing date.
+// Weeks start on Monday.
+func (p Period) Align(date time.Time) time.Time {
+	year, month, day := date.Date()
+	switch p.Interval {
+	case PDaily:
+		return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	case PWeekly, PBiweekly, PFortnightly:
+		offset := (int(date.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, date.Location())
+	case PMonthly, PBiMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+	case PQuarterly:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, date.Location())
+	case PYearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, date.Location())
+	default:
+		return date
+	}
 }
@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+func newMonthlyTestTransaction(start, end time.Time) *PeriodicTransaction {
+	pt := NewPeriodicTransaction()
+	pt.Period = Period{StartDate: start, EndDate: end, Interval: PMonthly, IntervalFrequency: 1}
+	p := NewPosting()
+	p.Transaction = &pt.Transaction
+	p.AccountPath = "Expenses:Groceries"
+	p.Amount = NewAmount("£", 20000)
+	pt.Transaction.AddPosting(p)
+	return &pt
+}
+
+func TestRunPeriodicTransaction(t *testing.T) {
+	october := time.Date(2020, time.October, 15, 0, 0, 0, 0, time.UTC)
+	pt := newMonthlyTestTransaction(october, time.Time{})
+
+	transactions := pt.Run(time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(transactions))
+	}
+	expected := []time.Time{
+		time.Date(2020, time.November, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, time.December, 15, 0, 0, 0, 0, time.UTC),
+	}
+	for i, transaction := range transactions {
+		if !transaction.Date.Equal(expected[i]) {
+			t.Fatalf("expected %s, got %s", expected[i], transaction.Date)
+		}
+		// Each transaction needs its own postings
+		if transaction.Postings[0] == pt.Transaction.Postings[0] || transaction.Postings[0].Transaction != &transactions[i] {
+			t.Fatalf("transaction shares postings with the periodic transaction")
+		}
+	}
+}
+
+func TestRunPeriodicTransactionBounds(t *testing.T) {
+	november := time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	// Without an end there is nothing to run
+	if transactions := newMonthlyTestTransaction(time.Time{}, time.Time{}).Run(november, time.Time{}); len(transactions) != 0 {
+		t.Fatalf("ran an unbounded periodic transaction")
+	}
+
+	// Without a start date, transactions line up with the calendar
+	transactions := newMonthlyTestTransaction(time.Time{}, time.Time{}).Run(november.AddDate(0, 0, 3), november.AddDate(0, 2, 0))
+	if len(transactions) != 1 || !transactions[0].Date.Equal(november.AddDate(0, 1, 0)) {
+		t.Fatalf("expected a single transaction on the first of december")
+	}
+
+	// The period's end is respected
+	transactions = newMonthlyTestTransaction(november, november.AddDate(0, 1, 0)).Run(november, november.AddDate(1, 0, 0))
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+}
+
+func TestPeriodAlign(t *testing.T) {
+	// A Wednesday
+	date := time.Date(2020, time.November, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval PeriodType
+		expected time.Time
+	}{
+		{PDaily, time.Date(2020, time.November, 18, 0, 0, 0, 0, time.UTC)},
+		{PWeekly, time.Date(2020, time.November, 16, 0, 0, 0, 0, time.UTC)},
+		{PMonthly, time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{PQuarterly, time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{PYearly, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := (Period{Interval: test.interval}).Align(date); !got.Equal(test.expected) {
+			t.Fatalf("aligning to %s: expected %s, got %s", test.interval, test.expected, got)
+		}
+	}
+}
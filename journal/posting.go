@@ -1,6 +1,6 @@
 package journal
 
-import "fmt"
+import "strings"
 
 // Posting holds details about a single Posting
 type Posting struct {
@@ -9,6 +9,8 @@ type Posting struct {
 	Account     *Account     // The account this posting relates to. Set when the parent transaction is linked.
 	AccountPath string       // The : delimited path to the above account. Set during parsing by the transaction builder.
 	Amount      *Amount
+	Assertion   *Amount // What the account's balance should be after this posting, if given
+	Line        int     // The line of the journal file the posting is on
 }
 
 // NewPosting creates a Posting
@@ -23,12 +25,20 @@ func NewPosting() *Posting {
 }
 
 func (p *Posting) String() string {
-	rs := fmt.Sprintf("%s    %s", p.AccountPath, p.Amount.DisplayableQuantity(true))
+	var b strings.Builder
+	p.writeTo(&b)
+	return b.String()
+}
+
+// writeTo writes the posting as String would
+func (p *Posting) writeTo(b *strings.Builder) {
+	b.WriteString(p.AccountPath + "    " + p.Amount.DisplayableQuantity(true))
+	if p.Assertion != nil {
+		b.WriteString(" = " + p.Assertion.DisplayableQuantity(true))
+	}
 	for _, c := range p.Comments {
-		rs = fmt.Sprintf("%s\n      ; %s", rs, c)
+		b.WriteString("\n      ; " + c)
 	}
-
-	return rs
 }
 
 // AddComment adds a comment to the posting
@@ -2,10 +2,17 @@ package journal
 
 import (
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrUnbalanced is returned when a transaction's postings do not sum to zero
+	ErrUnbalanced = errors.New("transaction does not balance")
+	// ErrMultipleElided is returned when more than one of a transaction's postings has no amount
+	ErrMultipleElided = errors.New("cannot have more than one posting with an elided amount")
+)
+
 //go:generate stringer -type=TransactionState
 // TransactionState represents the state a Transaction can be in
 type TransactionState int
@@ -38,6 +45,8 @@ type Transaction struct {
 	postingWithElidedAmount *Posting
 	HeaderNote              string   // note in the header
 	Notes                   []string // notes under the header
+	Path                    string   // the journal file the transaction was found in
+	Line                    int      // the line of the journal file the transaction starts on
 }
 
 // NewTransaction creates a transaction
@@ -45,30 +54,33 @@ func NewTransaction() Transaction {
 	return Transaction{
 		Date:                    time.Time{},
 		State:                   NoState,
-		Postings:                make([]*Posting, 0, 8),
+		Postings:                make([]*Posting, 0, 2), // most transactions have two postings
 		postingWithElidedAmount: nil,
-		Notes:                   make([]string, 0, 4),
+		Notes:                   nil,
 	}
 }
 
 func (t Transaction) String() string {
 	const dashDateFormat string = "2006-01-02"
-	date := t.Date.Format(dashDateFormat)
-	rs := fmt.Sprintf("%s %s %s", date, StateToString(t.State), t.Payee)
+	var b strings.Builder
+	b.WriteString(t.Date.Format(dashDateFormat))
+	b.WriteString(" " + StateToString(t.State) + " " + t.Payee)
 
 	if len(t.HeaderNote) > 0 {
-		rs = fmt.Sprintf("%s    ; %s", rs, t.HeaderNote)
+		b.WriteString("    ; " + t.HeaderNote)
 	}
 
 	for _, n := range t.Notes {
-		rs = fmt.Sprintf("%s\n    ; %s", rs, n)
+		b.WriteString("\n    ; " + n)
 	}
 
 	for _, p := range t.Postings {
-		rs = fmt.Sprintf("%s\n    %s", rs, p)
+		b.WriteString("\n    ")
+		p.writeTo(&b)
 	}
 
-	return fmt.Sprintf("%s\n", rs)
+	b.WriteString("\n")
+	return b.String()
 }
 
 // AddNote adds a note to the transaction
@@ -80,7 +92,7 @@ func (t *Transaction) AddNote(note string) {
 func (t *Transaction) AddPosting(p *Posting) error {
 	if p.Amount == nil {
 		if t.postingWithElidedAmount != nil {
-			return errors.New("cannot have more than one posting with an elided amount")
+			return ErrMultipleElided
 		}
 		t.postingWithElidedAmount = p
 	}
@@ -108,11 +120,12 @@ func (t *Transaction) Close() error {
 		sum += p.Amount.Quantity
 	}
 
-	if sum != 0 {
-		if t.postingWithElidedAmount == nil {
-			return errors.New("transaction does not balance")
-		}
+	if sum != 0 && t.postingWithElidedAmount == nil {
+		return ErrUnbalanced
+	}
 
+	// Elided postings always get an amount, even if the others already balance
+	if t.postingWithElidedAmount != nil {
 		// NB: setting the commodity like this will not work with multiple currencies
 		t.postingWithElidedAmount.Amount = NewAmount(c, -sum)
 	}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// benchmarkSizes are the numbers of transactions in the journals parsed by the benchmarks.
+// The largest is about the size of a journal kept for fifteen years.
+var benchmarkSizes = []int{1000, 80000}
+
+// generateJournal creates a journal of n transactions, a few a day, mixing the things journals hold:
+// states, notes, comments, commodities, elided postings, balance assertions, and budget and periodic transactions
+func generateJournal(n int) []byte {
+	payees := []string{"Supermarket", "Café", "Landlord", "Employer", "Bookshop", "Electricity company", "Bakery"}
+	expenses := []string{"Expenses:Food:Groceries", "Expenses:Food:Eating Out", "Expenses:Home:Rent", "Expenses:Home:Utilities:Electricity", "Expenses:Books", "Expenses:Travel:Train"}
+
+	var b strings.Builder
+	b.WriteString("; generated for benchmarks\naccount Assets:Current\ncommodity £1,000.00\n\n")
+	b.WriteString("~ monthly from 2006-01\n    Expenses:Food:Groceries    £200\n    Assets:Current\n\n")
+
+	date := time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		if i%3 == 0 {
+			date = date.AddDate(0, 0, 1)
+		}
+
+		switch {
+		case i%500 == 0:
+			fmt.Fprintf(&b, "~ %s\n    Groceries    £%d.00\n    Eating Out    £50.00\n    To Be Budgeted\n\n", date.Format("2006-01"), 150+i%50)
+		case i%40 == 0:
+			fmt.Fprintf(&b, "%s * %s  ; salary for %s\n    Assets:Current    £2%03d.00\n    Income:Salary\n\n", date.Format("2006-01-02"), payees[3], date.Format("January"), i%1000)
+		default:
+			state := []string{"", "* ", "! "}[i%3]
+			fmt.Fprintf(&b, "%s %s%s\n", date.Format("2006/01/02"), state, payees[i%len(payees)])
+			if i%7 == 0 {
+				b.WriteString("    ; paid with the joint card\n")
+			}
+			fmt.Fprintf(&b, "    %s    £%d.%02d\n", expenses[i%len(expenses)], i%90+1, i%100)
+			if i%11 == 0 {
+				fmt.Fprintf(&b, "    %s    £-%d.%02d\n    Liabilities:Credit Card\n", "Assets:Current", 1, 0)
+			} else {
+				b.WriteString("    Assets:Current\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		src := generateJournal(n)
+		b.Run(fmt.Sprintf("%d transactions", n), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := NewParser(func(t *journal.Transaction, _ string) error { return nil }, func(t *journal.PeriodicTransaction, _ string) error { return nil })
+				if err := p.Parse(bytes.NewReader(src), "benchmark.journal"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseIntoJournal(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		src := generateJournal(n)
+		b.Run(fmt.Sprintf("%d transactions", n), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				j := journal.NewJournal()
+				p := NewParser(func(t *journal.Transaction, path string) error {
+					j.AddTransaction(t, path)
+					for _, posting := range t.Postings {
+						if err := j.AddPosting(posting); err != nil {
+							return err
+						}
+					}
+					return nil
+				}, nil)
+				if err := p.Parse(bytes.NewReader(src), "benchmark.journal"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	src := generateJournal(benchmarkSizes[0])
+	options := DefaultFormatOptions()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Format(src, "test.journal", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGeneratedJournalParses(t *testing.T) {
+	src := generateJournal(benchmarkSizes[0])
+	transactions := 0
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		transactions++
+		return nil
+	}, func(t *journal.PeriodicTransaction, _ string) error {
+		transactions++
+		return nil
+	})
+	if err := p.Parse(bytes.NewReader(src), "benchmark.journal"); err != nil {
+		t.Fatalf("the generated journal does not parse: %s", err)
+	}
+	if transactions != benchmarkSizes[0]+1 {
+		t.Fatalf("expected %d transactions, got %d", benchmarkSizes[0]+1, transactions)
+	}
+}
@@ -64,8 +64,10 @@ func TestParseYearMonthIncorrectFormat(t *testing.T) {
 }
 
 func TestParseMonthDay(t *testing.T) {
+	// Dates without a year are assumed to be in the current year
+	year := time.Now().Year()
 	input := "06/22"
-	expected := time.Date(2020, time.June, 22, 0, 0, 0, 0, time.Local)
+	expected := time.Date(year, time.June, 22, 0, 0, 0, 0, time.Local)
 	got, err := ParseSmartDate(input)
 	if err != nil {
 		t.Fatalf("failed to parse year/month:\nerr: %s", err)
@@ -75,7 +77,7 @@ func TestParseMonthDay(t *testing.T) {
 	}
 
 	input = "06.22"
-	expected = time.Date(2020, time.June, 22, 0, 0, 0, 0, time.Local)
+	expected = time.Date(year, time.June, 22, 0, 0, 0, 0, time.Local)
 	got, err = ParseSmartDate(input)
 	if err != nil {
 		t.Fatalf("failed to parse year/month:\nerr: %s", err)
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// errTooManyErrors is recorded when parsing stops because the maximum number of errors was collected
+var errTooManyErrors = errors.New("too many errors")
+
+// Severity says how serious a Diagnostic is
+type Severity int
+
+// Severities a Diagnostic can have
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// MarshalText lets diagnostics be written as JSON for editors
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic describes a problem found while parsing a journal and where it was found
+type Diagnostic struct {
+	Path      string   `json:"path"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`     // the first column is 1
+	EndColumn int      `json:"end_column"` // the column after the problem
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+	Hint      string   `json:"hint,omitempty"`   // how the problem might be fixed
+	Source    string   `json:"source,omitempty"` // the line the problem is on
+	err       error
+}
+
+// newDiagnostic creates an error Diagnostic, taking its message and hint from err
+func newDiagnostic(path string, line int, source string, column, endColumn int, err error) *Diagnostic {
+	d := &Diagnostic{
+		Path:      path,
+		Line:      line,
+		Column:    column,
+		EndColumn: endColumn,
+		Severity:  SeverityError,
+		Message:   err.Error(),
+		Source:    source,
+		err:       err,
+	}
+
+	var hinted hintedError
+	if errors.As(err, &hinted) {
+		d.Hint = hinted.hint
+	} else if hint, found := hintFor(err); found {
+		d.Hint = hint
+	}
+
+	if d.EndColumn <= d.Column {
+		d.EndColumn = d.Column + 1
+	}
+	return d
+}
+
+// NewDiagnostic creates an error Diagnostic for a whole line of a journal.
+// It is for problems found after parsing, such as by checks.
+func NewDiagnostic(path string, line int, source, message, hint string) *Diagnostic {
+	indent := utf8.RuneCountInString(source) - utf8.RuneCountInString(strings.TrimLeft(source, " \t"))
+	d := newDiagnostic(path, line, source, indent+1, utf8.RuneCountInString(source)+1, errors.New(message))
+	d.Hint = hint
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Message)
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.err
+}
+
+// Render describes the diagnostic with the line it is on, underlining the problem:
+//
+//	error: invalid amount: 1x0
+//	  --> my.journal:13:23
+//	   |
+//	13 |     Expenses:Food    £1x0
+//	   |                       ^^^
+//	   = hint: amounts are numbers such as 10, -10.50 or +1000
+func (d *Diagnostic) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", d.Severity, d.Message)
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Line)))
+	fmt.Fprintf(&b, "%s--> %s:%d:%d\n", gutter, d.Path, d.Line, d.Column)
+
+	if len(d.Source) > 0 {
+		fmt.Fprintf(&b, "%s |\n", gutter)
+		fmt.Fprintf(&b, "%d | %s\n", d.Line, d.Source)
+		fmt.Fprintf(&b, "%s | %s\n", gutter, underline(d.Source, d.Column, d.EndColumn))
+	}
+
+	if len(d.Hint) > 0 {
+		fmt.Fprintf(&b, "%s = hint: %s\n", gutter, d.Hint)
+	}
+
+	return b.String()
+}
+
+// underline returns carets under the columns from start up to end, keeping tabs so they line up with the source
+func underline(source string, start, end int) string {
+	var b strings.Builder
+	column := 1
+	for _, r := range source {
+		if column >= start {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+		column++
+	}
+
+	width := end - start
+	if available := utf8.RuneCountInString(source) - start + 1; width > available && available > 0 {
+		width = available
+	}
+	if width < 1 {
+		width = 1
+	}
+	b.WriteString(strings.Repeat("^", width))
+	return b.String()
+}
+
+// Diagnostics are the problems collected while parsing a journal, in the order they were found
+type Diagnostics []*Diagnostic
+
+// Error renders every diagnostic followed by how many errors there were
+func (ds Diagnostics) Error() string {
+	var b strings.Builder
+	errorCount := 0
+	for _, d := range ds {
+		b.WriteString(d.Render())
+		b.WriteString("\n")
+		if d.Severity == SeverityError && !errors.Is(d, errTooManyErrors) {
+			errorCount++
+		}
+	}
+
+	switch errorCount {
+	case 0:
+	case 1:
+		b.WriteString("found 1 error")
+	default:
+		fmt.Fprintf(&b, "found %d errors", errorCount)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// hintedError is an error with a suggestion of how to fix it
+type hintedError struct {
+	error
+	hint string
+}
+
+func (e hintedError) Unwrap() error {
+	return e.error
+}
+
+// hintFor suggests how to fix the errors which come from outside the parser
+func hintFor(err error) (string, bool) {
+	switch {
+	case errors.Is(err, journal.ErrUnbalanced):
+		return "the amounts of a transaction's postings must add up to zero (or leave one amount out to balance it)", true
+	case errors.Is(err, journal.ErrMultipleElided):
+		return "only one posting in a transaction can leave its amount out", true
+	}
+	return "", false
+}
+
+// withHint adds a suggestion of how to fix an error
+func withHint(err error, hint string) error {
+	return hintedError{error: err, hint: hint}
+}
+
+// CollectErrors makes the parser carry on after an error rather than stopping.
+// The rest of the transaction with the error is skipped and parsing picks up again at the next transaction header.
+// Parsing stops once max errors have been collected (max <= 0 means there is no limit).
+// Parse then returns the errors as Diagnostics.
+func (p *Parser) CollectErrors(max int) {
+	p.collectErrors = true
+	p.maxErrors = max
+}
+
+// recordError collects an error and drops the transaction it was found in.
+// Returns the collected errors if there are too many to carry on.
+func (p *Parser) recordError(d *Diagnostic) error {
+	p.transactionBuilder.abandon()
+	p.diagnostics = append(p.diagnostics, d)
+
+	if p.maxErrors > 0 && len(p.diagnostics) >= p.maxErrors {
+		p.diagnostics = append(p.diagnostics, newDiagnostic(d.Path, d.Line, "", d.Column, d.Column, errTooManyErrors))
+		return p.diagnostics
+	}
+	return nil
+}
+
+// recoverFrom is called by the lexer when lexing a line fails.
+// Returns nil if the lexer should skip to the next transaction header and carry on.
+func (p *Parser) recoverFrom(err error) error {
+	var collected Diagnostics
+	if errors.As(err, &collected) {
+		return err
+	}
+
+	var d *Diagnostic
+	if !errors.As(err, &d) {
+		return err
+	}
+	return p.recordError(d)
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+const journalWithErrors = `2020-10-01 Pay
+    Income:Job    £-2000
+    Assets:Current
+
+2020-10-0x Broken date
+    Expenses:Food    £10
+    Assets:Current
+
+2020-10-03 Unbalanced
+    Expenses:Food    £10
+    Assets:Current    £-5
+2020-10-04 Shop
+    Expenses:Food    £1x0
+    Assets:Current
+
+2020-10-05 Fine
+    Expenses:Food    £10
+    Assets:Current
+`
+
+func TestParseStopsAtFirstError(t *testing.T) {
+	p := NewParser(nil, nil)
+	err := p.Parse(strings.NewReader(journalWithErrors), "test.journal")
+
+	var d *Diagnostic
+	if !errors.As(err, &d) {
+		t.Fatalf("expected a Diagnostic, got %v", err)
+	}
+	if d.Path != "test.journal" || d.Line != 5 || d.Column != 1 {
+		t.Fatalf("error is at the wrong location: %s", d)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	var payees []string
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		payees = append(payees, t.Payee)
+		return nil
+	}, nil)
+	p.CollectErrors(0)
+	err := p.Parse(strings.NewReader(journalWithErrors), "test.journal")
+
+	var collected Diagnostics
+	if !errors.As(err, &collected) {
+		t.Fatalf("expected Diagnostics, got %v", err)
+	}
+
+	expected := []struct{ line, column int }{{5, 1}, {9, 1}, {13, 23}}
+	if len(collected) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(expected), len(collected), collected)
+	}
+	for i, e := range expected {
+		if collected[i].Line != e.line || collected[i].Column != e.column {
+			t.Errorf("error %d: expected %d:%d, got %d:%d", i, e.line, e.column, collected[i].Line, collected[i].Column)
+		}
+	}
+
+	// Parsing picks up again at the next transaction header
+	if strings.Join(payees, ",") != "Pay,Fine" {
+		t.Fatalf("expected only the valid transactions to be handled, got %v", payees)
+	}
+}
+
+func TestCollectErrorsStopsAtMax(t *testing.T) {
+	p := NewParser(nil, nil)
+	p.CollectErrors(2)
+	err := p.Parse(strings.NewReader(journalWithErrors), "test.journal")
+
+	var collected Diagnostics
+	if !errors.As(err, &collected) {
+		t.Fatalf("expected Diagnostics, got %v", err)
+	}
+	if len(collected) != 3 || !errors.Is(collected[2], errTooManyErrors) {
+		t.Fatalf("expected parsing to stop after 2 errors, got:\n%s", collected)
+	}
+}
+
+func TestDiagnosticRender(t *testing.T) {
+	p := NewParser(nil, nil)
+	err := p.Parse(strings.NewReader("2020-10-04 Shop\n    Expenses:Food    £1x0\n    Assets:Current\n"), "test.journal")
+
+	var d *Diagnostic
+	if !errors.As(err, &d) {
+		t.Fatalf("expected a Diagnostic, got %v", err)
+	}
+	if d.Column != 23 || d.EndColumn != 26 {
+		t.Fatalf("expected the diagnostic to span 23-26, got %d-%d", d.Column, d.EndColumn)
+	}
+
+	expected := `error: invalid amount: 1x0
+ --> test.journal:2:23
+  |
+2 |     Expenses:Food    £1x0
+  |                       ^^^
+  = hint: amounts are numbers such as 10, -10.50 or +1000
+`
+	if got := d.Render(); got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDiagnosticMessages(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		hint    bool
+	}{
+		{"2020-10-04 Shop\n    Expenses:Food    £10\n    Assets:Current    £-5\n", "transaction does not balance", true},
+		{"2020-13-01 Shop\n    Expenses:Food    £10\n    Assets:Current\n", "invalid date: 2020-13-01", true},
+		{"2020-10-04 Shop\n!    Expenses:Food    £10\n", "unexpected line", true},
+		{"2020-10-04 Shop\nExpenses:Food    £10\n", "unknown directive: Expenses:Food", true},
+		{"~ fortnightly-ish\n    Food    £10\n", "unknown period: fortnightly-ish", true},
+		{"bucket Food\n", "unknown directive: bucket", true},
+		{"2020-10-04 Shop\n    Expenses:Food  £x\n", "missing amount", true},
+		{"year 20x0\n", "invalid year: 20x0", true},
+		{"Y\n", "year directive is missing a year", true},
+		{"D 1,000.00\n", "invalid default commodity: 1,000.00", true},
+	}
+
+	for _, test := range tests {
+		p := NewParser(nil, nil)
+		err := p.Parse(strings.NewReader(test.input), "test.journal")
+
+		var d *Diagnostic
+		if !errors.As(err, &d) {
+			t.Fatalf("%q: expected a Diagnostic, got %v", test.input, err)
+		}
+		if d.Message != test.message {
+			t.Errorf("%q: expected message %q, got %q", test.input, test.message, d.Message)
+		}
+		if test.hint && len(d.Hint) == 0 {
+			t.Errorf("%q: expected a hint", test.input)
+		}
+	}
+}
+
+func TestMissingAmountUnderlinesCommodity(t *testing.T) {
+	const input = "2020-10-04 Shop\n    Expenses:Food  £x\n    Assets:Current\n"
+	p := NewParser(nil, nil)
+	parseErr := p.Parse(strings.NewReader(input), "test.journal")
+	q := NewParser(nil, nil)
+	treeErr := q.ParseTree(ParseSyntax([]byte(input), "test.journal"))
+
+	for _, err := range []error{parseErr, treeErr} {
+		var d *Diagnostic
+		if !errors.As(err, &d) {
+			t.Fatalf("expected a Diagnostic, got %v", err)
+		}
+		if d.Message != "missing amount" || d.Line != 2 || d.Column != 20 || d.EndColumn != 22 {
+			t.Errorf("expected the commodity to be underlined, got %s at %d:%d-%d", d.Message, d.Line, d.Column, d.EndColumn)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// FormatOptions control how Format lays out a journal
+type FormatOptions struct {
+	Indent        int  // how many spaces postings are indented by
+	Column        int  // the column amounts end at (where accounts are short enough)
+	DateSeparator rune // the separator dates are written with: '-', '/' or '.'
+	Sort          bool // whether to sort transactions by date
+}
+
+// DefaultFormatOptions indents postings by four spaces and ends amounts at column 48
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		Indent:        4,
+		Column:        48,
+		DateSeparator: '-',
+	}
+}
+
+// formatEntryType is what a line (or lines) of a journal being formatted is
+type formatEntryType int
+
+const (
+	blankEntry formatEntryType = iota
+	commentEntry
+	directiveEntry
+	transactionEntry
+	periodicTransactionEntry
+	otherEntry
+)
+
+// formatEntry is a transaction (with any comments directly above it) or a single other line
+type formatEntry struct {
+	entryType formatEntryType
+	lines     []string
+	date      time.Time
+}
+
+// Format lays out a journal consistently: postings are indented the same way, amounts are aligned,
+// dates use the same separator and runs of blank lines become one. Comments are kept.
+// The journal must parse, and the formatted journal is checked to parse to the same transactions.
+// Included files are not followed. Errors name the journal by path.
+func Format(src []byte, path string, options FormatOptions) ([]byte, error) {
+	switch options.DateSeparator {
+	case '-', '/', '.':
+	default:
+		return nil, fmt.Errorf("dates cannot be separated by %q (use -, / or .)", options.DateSeparator)
+	}
+
+	tree := ParseSyntax(src, path)
+	original, err := parseForFormat(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := formatEntries(tree, options)
+	if err != nil {
+		return nil, err
+	}
+	entries = attachComments(entries)
+	if options.Sort {
+		sortEntries(entries)
+	}
+	formatted := layoutEntries(entries)
+
+	// Make sure nothing was lost
+	after, err := parseForFormat(ParseSyntax(formatted, path))
+	if err != nil {
+		return nil, fmt.Errorf("formatting would break the journal: %w", err)
+	}
+	if options.Sort {
+		sort.Strings(original)
+		sort.Strings(after)
+	}
+	if strings.Join(original, "\n") != strings.Join(after, "\n") {
+		return nil, errors.New("formatting would change the journal's transactions")
+	}
+
+	return formatted, nil
+}
+
+// parseForFormat describes each transaction, periodic transaction and declaration in a journal without following includes.
+// Whitespace at the end of lines (such as after a note) is ignored.
+// Every problem with the journal is returned as Diagnostics.
+func parseForFormat(tree *SyntaxTree) ([]string, error) {
+	described := make([]string, 0, 64)
+	describe := func(s string) {
+		lines := strings.Split(s, "\n")
+		for i := range lines {
+			lines[i] = strings.TrimRightFunc(lines[i], unicode.IsSpace)
+		}
+		described = append(described, strings.Join(lines, "\n"))
+	}
+
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		describe(t.String())
+		return nil
+	}, func(pt *journal.PeriodicTransaction, _ string) error {
+		describe(fmt.Sprintf("~ %v\n%s", pt.Period, pt.Transaction.String()))
+		return nil
+	})
+	p.HandleDeclarations(func(d journal.Declaration, _ string) error {
+		describe(fmt.Sprintf("%d %s", d.Type, d.Name))
+		return nil
+	})
+	p.skipIncludes = true
+	p.CollectErrors(0)
+
+	if err := p.ParseTree(tree); err != nil {
+		return nil, err
+	}
+	return described, nil
+}
+
+// formatEntries formats each node of a journal's syntax tree
+func formatEntries(tree *SyntaxTree, options FormatOptions) ([]formatEntry, error) {
+	entries := make([]formatEntry, 0, len(tree.Nodes))
+	year := 0 // the year of dates without one
+	for _, n := range tree.Nodes {
+		line := strings.TrimRightFunc(n.Text(), unicode.IsSpace)
+		switch n.Kind {
+		case BlankNode:
+			entries = append(entries, formatEntry{entryType: blankEntry})
+		case CommentNode:
+			entries = append(entries, formatEntry{entryType: commentEntry, lines: []string{line}})
+		case DirectiveNode:
+			if directive, _ := n.Token(DirectiveToken); isYearDirective(directive.Text) {
+				argument, _ := n.Token(ArgumentToken)
+				if y, err := parseYearArgument(argument.Text); err == nil {
+					year = y
+				}
+			}
+			entries = append(entries, formatEntry{entryType: directiveEntry, lines: []string{formatDirective(n)}})
+		case TransactionNode, PeriodicTransactionNode:
+			entry := formatEntry{entryType: transactionEntry}
+			if n.Kind == PeriodicTransactionNode {
+				entry.entryType = periodicTransactionEntry
+				entry.lines = append(entry.lines, formatPeriodHeader(n))
+			} else {
+				header, date, err := formatHeader(n, options.DateSeparator, year)
+				if err != nil {
+					return nil, err
+				}
+				entry.lines = append(entry.lines, header)
+				entry.date = date
+			}
+
+			// Comments at the end of a transaction are about what follows it
+			children := n.Children
+			end := len(children)
+			for end > 0 && (isUnindentedComment(children[end-1]) || isBlankText(children[end-1])) {
+				end--
+			}
+
+			inPosting := false
+			for _, c := range children[:end] {
+				switch {
+				case c.Kind == PostingNode:
+					entry.lines = append(entry.lines, formatPosting(c, options))
+					inPosting = true
+				case c.Kind == CommentNode && !isUnindentedComment(c):
+					// Comments are indented further once they belong to a posting
+					indent := options.Indent
+					if inPosting {
+						indent += 2
+					}
+					comment, _ := c.Token(CommentToken)
+					entry.lines = append(entry.lines, strings.Repeat(" ", indent)+strings.TrimRightFunc(comment.Text, unicode.IsSpace))
+				case !isBlankText(c):
+					entry.lines = append(entry.lines, strings.TrimRightFunc(c.Text(), unicode.IsSpace))
+				}
+			}
+			entries = append(entries, entry)
+
+			for _, c := range children[end:] {
+				if isUnindentedComment(c) {
+					entries = append(entries, formatEntry{entryType: commentEntry, lines: []string{strings.TrimRightFunc(c.Text(), unicode.IsSpace)}})
+				}
+			}
+		default:
+			entries = append(entries, formatEntry{entryType: otherEntry, lines: []string{line}})
+		}
+	}
+
+	return entries, nil
+}
+
+// isUnindentedComment reports whether a node is a comment at the start of its line
+func isUnindentedComment(n *SyntaxNode) bool {
+	return n.Kind == CommentNode && n.Tokens[0].Kind == CommentToken
+}
+
+// isBlankText reports whether a node is a line of only whitespace, which the parser ignores
+func isBlankText(n *SyntaxNode) bool {
+	return n.Kind == TextNode && len(strings.TrimSpace(n.Text())) == 0
+}
+
+// commentText is the text of a comment token without its indicator or the whitespace around it
+func commentText(t Token) string {
+	return strings.TrimSpace(t.Text[1:])
+}
+
+// formatHeader formats a transaction header as 'DATE [STATE] PAYEE  ; NOTE'.
+// Dates without a year are kept without one and are given the year provided.
+func formatHeader(n *SyntaxNode, separator rune, year int) (string, time.Time, error) {
+	dateToken, _ := n.Token(DateToken)
+	date, err := parseDateInYear([]byte(dateToken.Text), year)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid date: %s", dateToken.Text)
+	}
+
+	layout := "2006-01-02"
+	if _, _, found := splitDateWithoutYear(dateToken.Text); found {
+		layout = "01-02"
+	}
+
+	var b strings.Builder
+	b.WriteString(date.Format(strings.ReplaceAll(layout, "-", string(separator))))
+	if state, found := n.Token(StateToken); found {
+		b.WriteString(" " + state.Text)
+	}
+	if payee, found := n.Token(PayeeToken); found && len(payee.Text) > 0 {
+		b.WriteString(" " + payee.Text)
+	}
+
+	note := ""
+	if t, found := n.Token(NoteToken); found {
+		note = strings.TrimSpace(t.Text)
+	} else if t, found := n.Token(CommentToken); found {
+		note = commentText(t)
+	}
+	if len(note) > 0 {
+		b.WriteString("  ; " + note)
+	}
+	return b.String(), date, nil
+}
+
+// formatPeriodHeader formats a periodic transaction header as '~ PERIOD  ; COMMENT'
+func formatPeriodHeader(n *SyntaxNode) string {
+	// Anything else after the period is ignored by the parser so it is kept as it was
+	if _, found := n.Token(TextToken); found {
+		return strings.TrimRightFunc(n.Text(), unicode.IsSpace)
+	}
+
+	period, _ := n.Token(PeriodToken)
+	formatted := "~ " + period.Text
+	if comment, found := n.Token(CommentToken); found && len(commentText(comment)) > 0 {
+		formatted += "  ; " + commentText(comment)
+	}
+	return formatted
+}
+
+// formatDirective separates a directive from its argument and comment
+func formatDirective(n *SyntaxNode) string {
+	if _, found := n.Token(TextToken); found {
+		return strings.TrimRightFunc(n.Text(), unicode.IsSpace)
+	}
+
+	directive, _ := n.Token(DirectiveToken)
+	formatted := directive.Text
+	if argument, found := n.Token(ArgumentToken); found {
+		formatted += " " + argument.Text
+	}
+	if comment, found := n.Token(CommentToken); found && len(commentText(comment)) > 0 {
+		formatted += "  ; " + commentText(comment)
+	}
+	return formatted
+}
+
+// formatPosting formats a posting with its amount ending at the column
+func formatPosting(n *SyntaxNode, options FormatOptions) string {
+	account, _ := n.Token(AccountToken)
+	amount := ""
+	if commodity, found := n.Token(CommodityToken); found {
+		quantity, _ := n.Token(AmountToken)
+		amount = commodity.Text
+		// Commodities separated from the amount keep a single space
+		if len(commodity.Text) > 0 && commodity.Span.End < quantity.Span.Start {
+			amount += " "
+		}
+		amount += quantity.Text
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", options.Indent) + account.Text)
+	width := options.Indent + utf8.RuneCountInString(account.Text)
+	if len(amount) > 0 {
+		padding := options.Column - width - utf8.RuneCountInString(amount)
+		if padding < 2 {
+			padding = 2
+		}
+		b.WriteString(strings.Repeat(" ", padding) + amount)
+	}
+	if assertion, found := n.Token(AssertionToken); found {
+		if len(amount) == 0 {
+			b.WriteString("  ")
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString("= " + assertion.Text)
+	}
+	if comment, found := n.Token(CommentToken); found && len(commentText(comment)) > 0 {
+		b.WriteString("  ; " + commentText(comment))
+	}
+	if text, found := n.Token(TextToken); found {
+		b.WriteString("  " + strings.TrimRightFunc(text.Text, unicode.IsSpace))
+	}
+	return b.String()
+}
+
+// attachComments merges comments directly above a transaction into it so they move with it when sorting
+func attachComments(entries []formatEntry) []formatEntry {
+	attached := make([]formatEntry, 0, len(entries))
+	var comments []string
+	for _, e := range entries {
+		if e.entryType == commentEntry {
+			comments = append(comments, e.lines...)
+			continue
+		}
+		if e.entryType == transactionEntry && len(comments) > 0 {
+			e.lines = append(comments, e.lines...)
+			comments = nil
+		}
+		for _, c := range comments {
+			attached = append(attached, formatEntry{entryType: commentEntry, lines: []string{c}})
+		}
+		comments = nil
+		attached = append(attached, e)
+	}
+	for _, c := range comments {
+		attached = append(attached, formatEntry{entryType: commentEntry, lines: []string{c}})
+	}
+	return attached
+}
+
+// sortEntries sorts each run of transactions by date.
+// Runs are broken by anything other than transactions and blank lines.
+func sortEntries(entries []formatEntry) {
+	start := 0
+	for start < len(entries) {
+		end := start
+		for end < len(entries) && (entries[end].entryType == transactionEntry || entries[end].entryType == blankEntry) {
+			end++
+		}
+		if end == start {
+			start++
+			continue
+		}
+
+		// Keep the blank lines where they are and sort the transactions between them
+		run := entries[start:end]
+		transactions := make([]formatEntry, 0, len(run))
+		for _, e := range run {
+			if e.entryType == transactionEntry {
+				transactions = append(transactions, e)
+			}
+		}
+		sort.SliceStable(transactions, func(i, j int) bool {
+			return transactions[i].date.Before(transactions[j].date)
+		})
+		for i := range run {
+			if run[i].entryType == transactionEntry {
+				run[i], transactions = transactions[0], transactions[1:]
+			}
+		}
+
+		start = end
+	}
+}
+
+// layoutEntries joins the entries, keeping single blank lines and separating transactions with one
+func layoutEntries(entries []formatEntry) []byte {
+	var b bytes.Buffer
+	previous := blankEntry
+	for _, e := range entries {
+		if e.entryType == blankEntry {
+			// Drop blank lines at the start and after other blank lines
+			if previous == blankEntry {
+				continue
+			}
+			b.WriteString("\n")
+			previous = blankEntry
+			continue
+		}
+
+		if (previous == transactionEntry || previous == periodicTransactionEntry) &&
+			(e.entryType == transactionEntry || e.entryType == periodicTransactionEntry) {
+			b.WriteString("\n")
+		}
+		for _, line := range e.lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		previous = e.entryType
+	}
+
+	// End with a single newline
+	return append(bytes.TrimRight(b.Bytes(), "\n"), '\n')
+}
+
+// Includes lists the files a journal includes, in the order they are included
+func Includes(src []byte) []string {
+	includes := make([]string, 0)
+	for _, n := range ParseSyntax(src, "").Nodes {
+		directive, _ := n.Token(DirectiveToken)
+		if file, found := n.Token(ArgumentToken); found && directive.Text == "include" {
+			includes = append(includes, file.Text)
+		}
+	}
+	return includes
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+const unformattedJournal = `; My journal
+account Assets:Current  ; main account
+
+
+2020/10/05 * Pay ;monthly
+  Income:Job	£-2000.00
+	Assets:Current    = £2000.00
+    ; a posting comment
+; about the shop
+2020.10.03 Shop  ; :trip:
+  ; transaction note
+    Expenses:Food    £10   ; inline
+    Assets:Current  £-10 = £1990
+
+
+~ monthly  ; expected
+    Expenses:Food    £300
+    Assets:Current
+`
+
+const formattedJournal = `; My journal
+account Assets:Current  ; main account
+
+2020-10-05 * Pay  ; monthly
+    Income:Job                  £-2000.00
+    Assets:Current  = £2000.00
+      ; a posting comment
+
+; about the shop
+2020-10-03 Shop  ; :trip:
+    ; transaction note
+    Expenses:Food                     £10  ; inline
+    Assets:Current                   £-10 = £1990
+
+~ monthly  ; expected
+    Expenses:Food                    £300
+    Assets:Current
+`
+
+func testFormatOptions() FormatOptions {
+	options := DefaultFormatOptions()
+	options.Column = 41
+	return options
+}
+
+func TestFormat(t *testing.T) {
+	formatted, err := Format([]byte(unformattedJournal), "test.journal", testFormatOptions())
+	if err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+	if string(formatted) != formattedJournal {
+		t.Fatalf("formatted journal is wrong:\n%s", formatted)
+	}
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	for _, src := range []string{unformattedJournal, syntaxJournal} {
+		once, err := Format([]byte(src), "test.journal", testFormatOptions())
+		if err != nil {
+			t.Fatalf("formatting failed: %s", err)
+		}
+		twice, err := Format(once, "test.journal", testFormatOptions())
+		if err != nil {
+			t.Fatalf("formatting again failed: %s", err)
+		}
+		if string(once) != string(twice) {
+			t.Fatalf("formatting twice changed the journal:\n%s", twice)
+		}
+	}
+}
+
+func TestFormatSort(t *testing.T) {
+	options := testFormatOptions()
+	options.Sort = true
+	options.DateSeparator = '/'
+	formatted, err := Format([]byte(unformattedJournal), "test.journal", options)
+	if err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+
+	shop := strings.Index(string(formatted), "; about the shop\n2020/10/03 Shop")
+	pay := strings.Index(string(formatted), "2020/10/05 * Pay")
+	if shop == -1 || pay == -1 || shop > pay {
+		t.Fatalf("transactions were not sorted with their comments:\n%s", formatted)
+	}
+}
+
+func TestFormatLongAccount(t *testing.T) {
+	src := "2020-10-01 Shop\n    Expenses:A:Very:Long:Account:Name:Indeed £10\n    Expenses:A:Very:Long:Account:Name:Indeed  £10\n    Assets:Current\n"
+	formatted, err := Format([]byte(src), "test.journal", testFormatOptions())
+	if err == nil {
+		t.Fatalf("expected formatting to be refused, got:\n%s", formatted)
+	}
+
+	src = "2020-10-01 Shop\n    Expenses:A:Very:Long:Account:Name:Indeed  £10\n    Assets:Current\n"
+	formatted, err = Format([]byte(src), "test.journal", testFormatOptions())
+	if err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+	if !strings.Contains(string(formatted), "    Expenses:A:Very:Long:Account:Name:Indeed  £10\n") {
+		t.Fatalf("amount should be two spaces after a long account:\n%s", formatted)
+	}
+}
+
+func TestFormatRejectsBrokenJournal(t *testing.T) {
+	_, err := Format([]byte("2020-10-0x Shop\n    Expenses:Food  £10\n    Assets:Current\n"), "test.journal", testFormatOptions())
+	if err == nil {
+		t.Fatal("expected an error for a journal which does not parse")
+	}
+	var diagnostics Diagnostics
+	if !errors.As(err, &diagnostics) || len(diagnostics) != 1 || diagnostics[0].Path != "test.journal" || diagnostics[0].Line != 1 {
+		t.Fatalf("expected one diagnostic on line 1 of test.journal, got %v", err)
+	}
+	options := testFormatOptions()
+	options.DateSeparator = 'x'
+	if _, err := Format([]byte(formattedJournal), "test.journal", options); err == nil {
+		t.Fatal("expected an error for an invalid date separator")
+	}
+}
+
+func TestHeaderNoteAfterPayee(t *testing.T) {
+	for _, header := range []string{"2020-10-01 Shop;note", "2020-10-01 Shop ; note", "2020-10-01 Shop  ;note"} {
+		var parsed *journal.Transaction
+		p := NewParser(func(t *journal.Transaction, _ string) error {
+			parsed = t
+			return nil
+		}, nil)
+		src := header + "\n    Expenses:Food  £10\n    Assets:Current\n"
+		if err := p.Parse(strings.NewReader(src), "test.journal"); err != nil {
+			t.Fatalf("parsing %q failed: %s", header, err)
+		}
+		if parsed.Payee != "Shop" || parsed.HeaderNote != "note" {
+			t.Fatalf("%q parsed to payee %q and note %q", header, parsed.Payee, parsed.HeaderNote)
+		}
+	}
+}
+
+func TestFormatDatesWithoutYear(t *testing.T) {
+	const input = "year 2020\n\n10/05 Pay\n    Assets:Current  £10\n    Income\n\n10/3 Shop\n    Expenses:Food  £5\n    Assets:Current\n"
+	options := testFormatOptions()
+	options.Sort = true
+	options.DateSeparator = '.'
+	formatted, err := Format([]byte(input), "test.journal", options)
+	if err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+
+	shop := strings.Index(string(formatted), "\n10.03 Shop\n")
+	pay := strings.Index(string(formatted), "\n10.05 Pay\n")
+	if shop == -1 || pay == -1 || shop > pay {
+		t.Fatalf("dates without a year were not kept and sorted:\n%s", formatted)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzParseTreeMatchesParse needs Go 1.18 for fuzzing.
+// TestParseTreeMatchesParseOnComparisons checks its seeds with older versions.
+func FuzzParseTreeMatchesParse(f *testing.F) {
+	for _, src := range parseTreeComparisons {
+		f.Add([]byte(src))
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		// Included files would be read from wherever the fuzzer's input names
+		if bytes.Contains(src, []byte("include")) {
+			t.Skip()
+		}
+		compareParseTree(t, src)
+	})
+}
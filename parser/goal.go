@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+const goalTag string = "goal:"
+
+// ParseGoal parses a goal from a comment on a budget posting.
+// Goals take one of the forms:
+//
+//	goal: £1000            keep £1000 available in the envelope
+//	goal: £600 by 2020-12  have £600 available by December 2020
+//	goal: £150 monthly     budget £150 every month
+//
+// Returns false if the comment is not a goal.
+func ParseGoal(comment string) (journal.Goal, bool, error) {
+	goal := journal.Goal{}
+
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, goalTag) {
+		return goal, false, nil
+	}
+
+	fields := strings.Fields(comment[len(goalTag):])
+	if len(fields) == 0 {
+		return goal, true, fmt.Errorf("goal is missing an amount")
+	}
+
+	target, err := ParseCommodityAmount(fields[0])
+	if err != nil {
+		return goal, true, err
+	}
+	goal.Target = target
+
+	switch {
+	case len(fields) == 1:
+		goal.Type = journal.BalanceGoal
+	case len(fields) == 2 && fields[1] == "monthly":
+		goal.Type = journal.MonthlyGoal
+	case len(fields) == 3 && fields[1] == "by":
+		by, err := ParseSmartDate(fields[2])
+		if err != nil {
+			return goal, true, fmt.Errorf("error parsing goal date: %w", err)
+		}
+		goal.Type = journal.TargetDateGoal
+		goal.By = by
+	default:
+		return goal, true, fmt.Errorf("goal is malformed: %s", comment)
+	}
+
+	return goal, true, nil
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestParseGoal(t *testing.T) {
+	tests := []struct {
+		comment  string
+		expected journal.Goal
+	}{
+		{" goal: £1,000", journal.Goal{Type: journal.BalanceGoal, Target: journal.Amount{Commodity: "£", Quantity: 100000}}},
+		{"goal: £150.00 monthly", journal.Goal{Type: journal.MonthlyGoal, Target: journal.Amount{Commodity: "£", Quantity: 15000}}},
+		{"goal: £600 by 2020-12", journal.Goal{
+			Type:   journal.TargetDateGoal,
+			Target: journal.Amount{Commodity: "£", Quantity: 60000},
+			By:     time.Date(2020, time.December, 1, 0, 0, 0, 0, time.Local),
+		}},
+	}
+
+	for _, test := range tests {
+		got, found, err := ParseGoal(test.comment)
+		if err != nil || !found {
+			t.Fatalf("failed to parse goal '%s': %v", test.comment, err)
+		}
+		if got != test.expected {
+			t.Fatalf("parsing '%s'\nexpected\t%+v\ngot\t\t%+v", test.comment, test.expected, got)
+		}
+	}
+}
+
+func TestParseGoalIgnoresOtherComments(t *testing.T) {
+	if _, found, err := ParseGoal(" bought in the sale"); found || err != nil {
+		t.Fatalf("treated a comment as a goal")
+	}
+}
+
+func TestParseGoalMalformed(t *testing.T) {
+	for _, comment := range []string{"goal:", "goal: £600 by", "goal: £600 weekly", "goal: £"} {
+		if _, _, err := ParseGoal(comment); err == nil {
+			t.Fatalf("should have errored for goal '%s'", comment)
+		}
+	}
+}
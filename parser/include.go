@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveInclude finds the files an include directive in the file at from refers to.
+// Paths are relative to the directory of the file including them, can start with ~
+// and can be glob patterns (such as 20*/*.journal), whose matches are in lexical order.
+func ResolveInclude(from, pattern string) ([]string, error) {
+	path := pattern
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the home directory for %s: %w", pattern, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+
+	if !isGlob(path) {
+		return []string{path}, nil
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, withHint(fmt.Errorf("invalid include pattern: %s", pattern), "patterns use * and ? for any characters and [a-z] for ranges")
+	}
+	if len(matches) == 0 {
+		return nil, withHint(fmt.Errorf("include pattern matched no files: %s", pattern), "include paths are relative to the file which includes them")
+	}
+	return matches, nil
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// include parses the files an include directive refers to.
+// A pattern which matches the file including it skips that file.
+func (p *Parser) include(pattern string) error {
+	from := p.journalFiles[len(p.journalFiles)-1]
+	paths, err := ResolveInclude(from, pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if isGlob(pattern) && samePath(path, from) {
+			continue
+		}
+		if err := p.includeFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// includeFile parses an included file, making sure it is not already being parsed
+func (p *Parser) includeFile(path string) error {
+	for i, f := range p.journalFiles {
+		if samePath(f, path) {
+			cycle := append(append([]string{}, p.journalFiles[i:]...), path)
+			return withHint(fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> ")), "a file cannot include itself, directly or through the files it includes")
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return withHint(fmt.Errorf("included file not found: %s", path), "include paths are relative to the file which includes them")
+		}
+		return err
+	}
+	defer file.Close()
+
+	p.journalFiles = append(p.journalFiles, path)
+	defer func() { p.journalFiles = p.journalFiles[:len(p.journalFiles)-1] }()
+
+	return p.lex(file, path)
+}
+
+// samePath reports whether two paths are the same file
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
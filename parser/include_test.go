@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// writeJournals writes journal files under dir, creating any directories they need
+func writeJournals(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// transactionPaths parses the journal at name in dir and lists each transaction's payee and the file (relative to dir) it was found in
+func transactionPaths(dir, name string) ([]string, error) {
+	found := make([]string, 0)
+	p := NewParser(func(t *journal.Transaction, path string) error {
+		rel, err := filepath.Rel(dir, path)
+		found = append(found, t.Payee+" "+filepath.ToSlash(rel))
+		return err
+	}, nil)
+
+	path := filepath.Join(dir, name)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return found, p.Parse(file, path)
+}
+
+const includedTransaction = "2020-01-01 %s\n    Expenses:Food  £1\n    Assets:Current\n"
+
+func transaction(payee string) string {
+	return strings.Replace(includedTransaction, "%s", payee, 1)
+}
+
+func TestNestedIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeJournals(t, dir, map[string]string{
+		"main.journal":         transaction("Main") + "include sub/outer.journal\n" + transaction("After"),
+		"sub/outer.journal":    "include inner.journal\n\n" + transaction("Outer"),
+		"sub/inner.journal":    transaction("Inner"),
+		"2019/a.journal":       transaction("2019"),
+		"2020/b.journal":       transaction("2020"),
+		"globbed.journal":      "include 20*/*.journal\ninclude *.journal\n",
+		"home/.ledger/journal": transaction("Home"),
+		"home/tilde.journal":   "include ~/.ledger/journal\n",
+	})
+
+	for name, expected := range map[string]string{
+		"main.journal": "Main main.journal, Inner sub/inner.journal, Outer sub/outer.journal, After main.journal",
+		// Globs match in order and skip the file including them
+		"globbed.journal": "2019 2019/a.journal, 2020 2020/b.journal, Main main.journal, Inner sub/inner.journal, Outer sub/outer.journal, After main.journal",
+	} {
+		paths, err := transactionPaths(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(paths, ", ") != expected {
+			t.Fatalf("%s: expected %s, got %s", name, expected, strings.Join(paths, ", "))
+		}
+	}
+
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", filepath.Join(dir, "home"))
+	paths, err := transactionPaths(dir, "home/tilde.journal")
+	if err != nil || strings.Join(paths, ", ") != "Home home/.ledger/journal" {
+		t.Fatalf("~ was not expanded: %v %v", paths, err)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeJournals(t, dir, map[string]string{
+		"a.journal":       "include b.journal\n",
+		"b.journal":       transaction("B") + "\ninclude a.journal\n",
+		"self.journal":    "include self.journal\n",
+		"missing.journal": "\ninclude nope.journal\n",
+		"nomatch.journal": "include 19*/*.journal\n",
+	})
+
+	for name, message := range map[string]string{
+		"a.journal":       "include cycle: " + filepath.Join(dir, "a.journal") + " -> " + filepath.Join(dir, "b.journal") + " -> " + filepath.Join(dir, "a.journal"),
+		"self.journal":    "include cycle: ",
+		"missing.journal": "included file not found: " + filepath.Join(dir, "nope.journal"),
+		"nomatch.journal": "include pattern matched no files: 19*/*.journal",
+	} {
+		_, err := transactionPaths(dir, name)
+		var d *Diagnostic
+		if !errors.As(err, &d) {
+			t.Fatalf("%s: expected a Diagnostic, got %v", name, err)
+		}
+		if !strings.HasPrefix(d.Message, message) || len(d.Hint) == 0 {
+			t.Fatalf("%s: expected %q, got %q", name, message, d.Message)
+		}
+	}
+
+	// The error is reported where the file is included
+	_, err := transactionPaths(dir, "missing.journal")
+	var d *Diagnostic
+	if errors.As(err, &d); d.Path != filepath.Join(dir, "missing.journal") || d.Line != 2 {
+		t.Fatalf("error is at the wrong location: %s", d)
+	}
+}
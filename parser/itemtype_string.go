@@ -19,12 +19,14 @@ func _() {
 	_ = x[commentItem-8]
 	_ = x[transactionHeaderCommentItem-9]
 	_ = x[periodItem-10]
-	_ = x[eofItem-11]
+	_ = x[assertionItem-11]
+	_ = x[directiveItem-12]
+	_ = x[eofItem-13]
 }
 
-const _itemType_name = "emptyLineItemincludeItemdateItemstateItempayeeItemaccountItemcommodityItemamountItemcommentItemtransactionHeaderCommentItemperiodItemeofItem"
+const _itemType_name = "emptyLineItemincludeItemdateItemstateItempayeeItemaccountItemcommodityItemamountItemcommentItemtransactionHeaderCommentItemperiodItemassertionItemdirectiveItemeofItem"
 
-var _itemType_index = [...]uint8{0, 13, 24, 32, 41, 50, 61, 74, 84, 95, 123, 133, 140}
+var _itemType_index = [...]uint8{0, 13, 24, 32, 41, 50, 61, 74, 84, 95, 123, 133, 146, 159, 166}
 
 func (i itemType) String() string {
 	if i < 0 || i >= itemType(len(_itemType_index)-1) {
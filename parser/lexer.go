@@ -4,15 +4,11 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
-	"fmt"
 	"io"
-	"log"
-	"path/filepath"
 	"unicode"
 	"unicode/utf8"
-
-	"github.com/rikchilvers/gledger/shared"
 )
 
 //go:generate stringer -type=itemType
@@ -30,23 +26,59 @@ const (
 	commentItem
 	transactionHeaderCommentItem
 	periodItem
+	assertionItem
+	directiveItem
 	eofItem
 )
 
-const (
-	eof                = -1
-	runeBufferCapacity = 256
-)
+// description names the item in a way that makes sense in error messages
+func (t itemType) description() string {
+	switch t {
+	case emptyLineItem:
+		return "an empty line"
+	case includeItem:
+		return "an include directive"
+	case dateItem:
+		return "a date"
+	case stateItem:
+		return "a state (* or !)"
+	case payeeItem:
+		return "a payee"
+	case accountItem:
+		return "an account"
+	case commodityItem:
+		return "a commodity"
+	case amountItem:
+		return "an amount"
+	case commentItem, transactionHeaderCommentItem:
+		return "a comment"
+	case periodItem:
+		return "a period"
+	case assertionItem:
+		return "a balance assertion"
+	case directiveItem:
+		return "a directive"
+	case eofItem:
+		return "the end of the file"
+	default:
+		return "the start of a transaction"
+	}
+}
+
+const eof = -1
 
 type lexer struct {
 	locationHint string
 	reader       *bufio.Reader
 	input        []byte
+	long         []byte // holds lines which are too long to be read at once
 	currentLine  int
 	pos          int // input position
 	start        int // item start position
 	width        int // width of last element
 	parser       itemParser
+	recover      func(err error) error // if set, called with each line's errors and lexing carries on if it returns nil
+	skipping     bool                  // whether lines are being skipped until the next transaction header
 }
 
 func newLexer(reader io.Reader, locationHint string, parser itemParser) lexer {
@@ -64,36 +96,52 @@ func newLexer(reader io.Reader, locationHint string, parser itemParser) lexer {
 
 // only used during testing
 func (l *lexer) ingest() error {
-	line, isPrefix, err := l.reader.ReadLine()
+	line, err := l.readLine()
 	if err != nil {
 		return err
 	}
-	if isPrefix {
-		log.Fatalln("Unhandled split line (todo)")
-	}
 	l.input = line
 	return nil
 }
 
+// readLine reads the next line (of any length) without its line ending.
+// The line is only valid until the next call.
+func (l *lexer) readLine() ([]byte, error) {
+	line, isPrefix, err := l.reader.ReadLine()
+	if err != nil || !isPrefix {
+		return line, err
+	}
+
+	// The line is longer than the reader's buffer so gather it piece by piece
+	l.long = append(l.long[:0], line...)
+	for isPrefix {
+		line, isPrefix, err = l.reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.long = append(l.long, line...)
+	}
+	return l.long, nil
+}
+
 // Lexes the file line by line
 func (l *lexer) lex() error {
 	l.currentLine = 1
 	for {
-		line, isPrefix, err := l.reader.ReadLine()
+		line, err := l.readLine()
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
 				// Let the parser know we have reached the end of the file
-				parseError := l.parser(eofItem, nil)
-				if parseError != nil {
-					return parseError
+				if parseError := l.parser(eofItem, nil); parseError != nil {
+					return l.locate(parseError)
 				}
 				break
 			}
 			return err
 		}
-		if isPrefix {
-			log.Fatalln("Unhandled split line (todo)")
-		}
 
 		// Reset the positions
 		l.pos = 0
@@ -101,9 +149,22 @@ func (l *lexer) lex() error {
 		l.width = 0
 
 		l.input = line
+		if l.skipping && !isTransactionHeader(line) {
+			l.currentLine++
+			continue
+		}
+		l.skipping = false
+
 		if err = l.lexLine(); err != nil {
 			// Add line data and pass up the error
-			return fmt.Errorf("%s:%d\n%w", l.locationHint, l.currentLine, err)
+			err = l.locate(err)
+			if l.recover == nil {
+				return err
+			}
+			if err = l.recover(err); err != nil {
+				return err
+			}
+			l.skipping = true
 		}
 		l.currentLine++
 	}
@@ -111,84 +172,104 @@ func (l *lexer) lex() error {
 	return nil
 }
 
-// Lex the line
-func (l *lexer) lexLine() error {
-	// Bail early if the line is empty
-	if len(l.input) == 0 {
-		return l.parser(emptyLineItem, nil)
-	}
-
-	// We take the first rune rather than peeking so that we can detect posting lines
-	firstRune := l.next()
-
-	// Bail if the line is a comment
-	if isCommentIndicator(firstRune) {
-		return nil
+// locate turns an error into a Diagnostic for the item being lexed, unless it already is one
+func (l *lexer) locate(err error) error {
+	var d *Diagnostic
+	var ds Diagnostics
+	if errors.As(err, &d) || errors.As(err, &ds) {
+		return err
 	}
 
-	// Handle include directives
-	if firstRune == 'i' {
-		l.backup()
-		return l.lexIncludeDirective()
+	column := func(pos int) int {
+		if pos > len(l.input) {
+			pos = len(l.input)
+		}
+		return utf8.RuneCount(l.input[:pos]) + 1
 	}
+	return newDiagnostic(l.locationHint, l.currentLine, string(l.input), column(l.start), column(l.pos), err)
+}
 
-	// Handle EOF
-	// This will probably only be called during tests
-	if firstRune == eof {
-		return l.parser(eofItem, nil)
+// isTransactionHeader reports whether a line starts a transaction or periodic transaction
+func isTransactionHeader(line []byte) bool {
+	switch classifyLine(line) {
+	case headerLine, periodicHeaderLine:
+		return true
+	default:
+		return false
 	}
+}
 
-	// Handle periodic transactions
-	if firstRune == '~' {
+// Lex the line
+func (l *lexer) lexLine() error {
+	switch classifyLine(l.input) {
+	case blankLine:
+		return l.parser(emptyLineItem, nil)
+	case commentLine:
+		return nil
+	case directiveLine:
+		return l.lexDirective()
+	case periodicHeaderLine:
+		l.next() // the '~'
 		return l.lexPeriodTransactionHeader()
-	}
-
-	// Detect transaction headers
-	if unicode.IsNumber(firstRune) {
-		// Need to backup to include the first rune
-		l.backup()
+	case headerLine:
 		return l.lexTransactionHeader()
-	}
-
-	// Detect posting lines
-	if firstRune == '\t' || (firstRune == ' ' && l.peek() == ' ') {
+	case postingLine:
 		return l.lexPosting()
 	}
 
-	return errors.New("unexpected line type during lexing")
+	l.pos = len(l.input)
+	return withHint(errors.New("unexpected line"), "transactions start with a date and their postings are indented by a tab or at least two spaces")
 }
 
-func (l *lexer) lexIncludeDirective() error {
-	if directive := l.takeUntilSpace(); !equal(directive, []rune("include")) {
-		return fmt.Errorf("unexpected directive: %s", string(directive))
+// lexDirective lexes a directive according to its name.
+// Include directives are lexed here as they need to know where the file is.
+// Other directives (account, commodity, D, payee, tag and year) are passed to the parser as their name and argument,
+// which also reports those it does not know.
+func (l *lexer) lexDirective() error {
+	l.pos = directiveNameEnd(l.input)
+	directive := l.input[:l.pos]
+	if string(directive) == "include" {
+		return l.lexIncludeDirective()
 	}
 
+	// The parser is given the name and argument separated by a single space
+	l.consumeSpace()
+	start := l.pos
+	argument := l.takeToTabOrNextLineOrComment()
+	if len(argument) == 0 {
+		l.pos = len(directive)
+		return l.parser(directiveItem, directive)
+	}
+	l.pos = start + len(argument)
+	content := make([]byte, 0, len(directive)+1+len(argument))
+	content = append(append(append(content, directive...), ' '), argument...)
+	return l.parser(directiveItem, content)
+}
+
+func (l *lexer) lexIncludeDirective() error {
 	if l.consumeSpace() == 0 {
-		return errors.New("could not lex include directive")
+		return withHint(errors.New("include is missing a file"), "include a file with 'include path/to/file.journal'")
 	}
 
+	l.start = l.pos
 	fileToInclude := l.takeToTabOrNextLineOrComment()
 
 	if len(fileToInclude) == 0 {
-		return errors.New("could not lex include directive")
+		return withHint(errors.New("include is missing a file"), "include a file with 'include path/to/file.journal'")
 	}
 
-	// We need to add any directory information here
-	// because the parser does not know about where we're including from
-	dir, _ := filepath.Split(l.locationHint)
-	fileToInclude = append([]rune(dir), fileToInclude...)
-
-	return l.parser(includeItem, fileToInclude)
+	return l.parseTaken(includeItem, fileToInclude)
 }
 
 func (l *lexer) lexPeriodTransactionHeader() error {
 	spaces := l.consumeSpace()
 	if spaces == 0 {
-		return errors.New("not enough spaces following ~")
+		return withHint(errors.New("periodic transaction is missing a period"), "put a space between ~ and the period, as in '~ 2020-06' or '~ monthly'")
 	}
 
+	l.start = l.pos
 	period := l.takeToTabOrNextLineOrComment()
-	return l.parser(periodItem, period)
+	return l.parseTaken(periodItem, period)
 }
 
 func (l *lexer) lexTransactionHeader() error {
@@ -200,12 +281,13 @@ func (l *lexer) lexTransactionHeader() error {
 	}
 
 	l.consumeSpace()
+	l.start = l.pos
 	next := l.next()
 	if next == '!' {
-		err = l.parser(stateItem, []rune{'!'})
+		err = l.parser(stateItem, l.input[l.start:l.pos])
 		l.consumeSpace()
 	} else if next == '*' {
-		err = l.parser(stateItem, []rune{'*'})
+		err = l.parser(stateItem, l.input[l.start:l.pos])
 		l.consumeSpace()
 	} else {
 		l.backup()
@@ -214,14 +296,21 @@ func (l *lexer) lexTransactionHeader() error {
 		return err
 	}
 
+	l.start = l.pos
 	payee := l.takeToTabOrNextLineOrComment()
-	if err = l.parser(payeeItem, payee); err != nil {
+	if err = l.parseTaken(payeeItem, payee); err != nil {
 		return err
 	}
 
-	comment := l.takeToNextLine()
+	// The payee ends at a comment indicator (which has been taken) or before one
+	l.start = l.pos
+	afterIndicator := l.pos > 0 && isCommentIndicator(rune(l.input[l.pos-1]))
+	comment := trimSpaceStart(l.takeToNextLine())
+	if !afterIndicator && len(comment) > 0 && isCommentIndicator(rune(comment[0])) {
+		comment = comment[1:]
+	}
 	if len(comment) > 0 {
-		c := trimSpaceStart(comment[1:])
+		c := trimSpaceStart(comment)
 		if err = l.parser(transactionHeaderCommentItem, c); err != nil {
 			return err
 		}
@@ -232,6 +321,7 @@ func (l *lexer) lexTransactionHeader() error {
 
 func (l *lexer) lexPosting() error {
 	l.consumeSpace()
+	l.start = l.pos
 
 	firstRune := l.next()
 
@@ -245,33 +335,57 @@ func (l *lexer) lexPosting() error {
 		// We need to backup otherwise we'll miss the first rune of the account
 		l.backup()
 		account := l.takeUntilMoreThanOneSpace()
-		if err := l.parser(accountItem, account); err != nil {
+		if err := l.parseTaken(accountItem, account); err != nil {
 			return err
 		}
 
-		// Bail if there are not enough spaces
+		// Bail if there are not enough spaces (or only spaces) after the account
+		l.start = l.pos
 		if l.consumeSpace() < 2 {
 			if len(l.input)-l.pos > 1 {
-				return errors.New("not enough spaces following account")
+				return withHint(errors.New("not enough spaces following account"), "separate the account from its amount with at least two spaces or a tab")
 			}
 			return nil
 		}
+		if l.pos == len(l.input) {
+			return nil
+		}
+
+		// Postings can assert the account's balance without an amount
+		if l.peek() == '=' {
+			return l.lexAssertion()
+		}
 
 		// Lex the commodity
+		l.start = l.pos
 		commodity := l.lexCommodity()
-		if l.consumeSpace() > 0 {
-			commodity = append(commodity, ' ')
+		if spaces, _ := spaceEnd(l.input, l.pos); spaces > l.pos {
+			// Copy the commodity rather than appending to it, which would write over the line
+			commodity = append(commodity[:len(commodity):len(commodity)], ' ')
 		}
 		if err := l.parser(commodityItem, commodity); err != nil {
 			return err
 		}
+		commodityStart, commodityEnd := l.start, l.pos
+		l.consumeSpace()
 
-		// Lex the amount
-		amount := l.takeToTabOrNextLineOrComment()
-		if err := l.parser(amountItem, amount); err != nil {
+		// Lex the amount, which stops before the = of a balance assertion
+		l.start = l.pos
+		amount := l.takeAmount()
+		if len(amount) == 0 {
+			// Everything after the account was taken as the commodity (as in £x)
+			l.start, l.pos = commodityStart, commodityEnd
+			return errMissingAmount()
+		}
+		if err := l.parseTaken(amountItem, amount); err != nil {
 			return err
 		}
 
+		l.consumeSpace()
+		if l.peek() == '=' {
+			return l.lexAssertion()
+		}
+
 		return nil
 	}
 
@@ -281,27 +395,37 @@ func (l *lexer) lexPosting() error {
 	return nil
 }
 
-// Takes until a number or a space
-func (l *lexer) lexCommodity() []rune {
-	runes := make([]rune, 0, runeBufferCapacity)
-	for {
-		r := l.next()
-		if r == eof {
-			return runes
-		}
-
-		if unicode.IsNumber(r) || r == '-' || r == '+' {
-			l.backup()
-			return runes
-		}
+// lexAssertion lexes a balance assertion such as '= £100'
+func (l *lexer) lexAssertion() error {
+	equals := l.pos
+	l.next() // the '='
+	l.consumeSpace()
+	l.start = l.pos
+	assertion := l.takeToTabOrNextLineOrComment()
+	if len(assertion) == 0 {
+		l.start = equals
+		return withHint(errors.New("balance assertion is missing an amount"), "assert the account's balance after the posting with '= £100'")
+	}
+	return l.parseTaken(assertionItem, assertion)
+}
 
-		if r == ' ' || r == '\t' {
-			l.backup()
-			return runes
-		}
+// Takes until a number or a space
+func (l *lexer) lexCommodity() []byte {
+	start := l.pos
+	l.pos = commodityEnd(l.input, start)
+	return l.input[start:l.pos]
+}
 
-		runes = append(runes, r)
+// parseTaken passes what was just taken from l.start to the parser.
+// Errors are located at what was taken rather than at where lexing carries on from.
+func (l *lexer) parseTaken(t itemType, taken []byte) error {
+	next := l.pos
+	l.pos = l.start + len(taken)
+	if err := l.parser(t, taken); err != nil {
+		return err
 	}
+	l.pos = next
+	return nil
 }
 
 // Move through the bytes of the input, converting to runes as we go
@@ -332,145 +456,72 @@ func (l *lexer) backup() {
 // Consumes spaces
 // Returns how many spaces were consumed
 func (l *lexer) consumeSpace() int {
-	count := 0
-	for {
-		r := l.next()
-		if r == eof || !unicode.IsSpace(r) {
-			l.backup()
-			return count
-		}
-		if r == '\t' {
-			count += shared.TabWidth
-		}
-		if r == ' ' {
-			count++
-		}
-	}
+	end, count := spaceEnd(l.input, l.pos)
+	l.pos = end
+	return count
 }
 
 func isCommentIndicator(r rune) bool {
 	return r == ';' || r == '#'
 }
 
-func (l *lexer) takeToNextLine() []rune {
-	runes := make([]rune, 0, runeBufferCapacity)
-	for {
-		r := l.next()
-		if r == eof {
-			return trimSpaceStart(runes)
-		}
-		runes = append(runes, r)
-	}
+// isDigit reports whether a byte is an ASCII digit
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
 }
 
-func (l *lexer) takeToTabOrNextLineOrComment() []rune {
-	runes := make([]rune, 0, runeBufferCapacity)
-	trimSpace := func(runes []rune) []rune {
-		if len(runes) == 0 {
-			return runes
-		} else if runes[len(runes)-1] == ' ' {
-			return runes[:len(runes)-1]
-		} else {
-			return runes
-		}
-	}
-	for {
-		r := l.next()
-		if r == eof {
-			return trimSpace(runes)
-		}
-
-		if isCommentIndicator(r) {
-			return trimSpace(runes)
-		}
+// The take functions return parts of the line being lexed without copying them.
+// What they return is only valid until the next line is read.
 
-		if r == '\t' {
-			return trimSpace(runes)
-		}
-
-		if r == ' ' && l.peek() == ' ' {
-			return runes
-		}
-
-		runes = append(runes, r)
-	}
+func (l *lexer) takeToNextLine() []byte {
+	taken := l.input[l.pos:]
+	l.pos = len(l.input)
+	return trimSpaceStart(taken)
 }
 
-func (l *lexer) takeUntilSpace() []rune {
-	defer l.backup()
-	runes := make([]rune, 0, runeBufferCapacity)
-	for {
-		r := l.next()
-
-		if r == eof {
-			return runes
-		}
-
-		if r == ' ' {
-			return runes
-		}
-
-		runes = append(runes, r)
-	}
+// takeToTabOrNextLineOrComment takes up to (and past) a tab or comment indicator, or up to two spaces.
+// A single trailing space is dropped.
+func (l *lexer) takeToTabOrNextLineOrComment() []byte {
+	start := l.pos
+	end, next := fieldEnd(l.input, start)
+	l.pos = next
+	return l.input[start:end]
 }
 
-func (l *lexer) takeUntilMoreThanOneSpace() []rune {
-	// TODO: make this runes slice a buffer on the lexer
-	runes := make([]rune, 0, runeBufferCapacity)
-	var previous rune = -1
-	for {
-		r := l.peek()
-
-		if r == eof || r == '\t' {
-			if previous == ' ' {
-				// Drop the trailing space
-				return runes[:len(runes)-1]
-			}
-			return runes
-		}
-
-		if r == ' ' {
-			if previous == ' ' {
-				l.backup()
-				// Drop the space we appended last time
-				return runes[:len(runes)-1]
-			}
-			previous = r
-		} else {
-			previous = -1
-		}
+// takeAmount takes up to where a field would end or the = of a balance assertion
+func (l *lexer) takeAmount() []byte {
+	start := l.pos
+	end, next := amountEnd(l.input, start)
+	l.pos = next
+	return l.input[start:end]
+}
 
-		l.next()
-		runes = append(runes, r)
-	}
+// takeUntilSpace takes up to a space or tab
+func (l *lexer) takeUntilSpace() []byte {
+	start := l.pos
+	l.pos = wordEnd(l.input, start)
+	return l.input[start:l.pos]
 }
 
-// Equal tells whether a and b contain the same elements.
-// A nil argument is equivalent to an empty slice.
-func equal(a, b []rune) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i, v := range a {
-		if v != b[i] {
-			return false
-		}
-	}
-	return true
+func (l *lexer) takeUntilMoreThanOneSpace() []byte {
+	start := l.pos
+	end, next := accountEnd(l.input, start)
+	l.pos = next
+	return l.input[start:end]
 }
 
-func trimSpaceStart(runes []rune) []rune {
-	if len(runes) == 0 {
-		return runes
+// trimOneSpaceEnd drops a single trailing space
+func trimOneSpaceEnd(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == ' ' {
+		return b[:len(b)-1]
 	}
+	return b
+}
 
-	index := 0
-	for i, r := range runes {
-		if r != ' ' {
-			index = i
-			break
-		}
-	}
+func trimSpaceEnd(b []byte) []byte {
+	return bytes.TrimRightFunc(b, unicode.IsSpace)
+}
 
-	return runes[index:]
+func trimSpaceStart(b []byte) []byte {
+	return bytes.TrimLeft(b, " \t")
 }
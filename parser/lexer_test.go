@@ -3,6 +3,8 @@ package parser
 import (
 	"strings"
 	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
 )
 
 const transactionHeaderWithComment string = "2020-10-11 A shop"
@@ -19,7 +21,7 @@ type mockParser struct {
 	lexedPayee bool
 }
 
-func (p *mockParser) parseItem(t itemType, content []rune) error {
+func (p *mockParser) parseItem(t itemType, content []byte) error {
 	switch t {
 	case dateItem:
 		if string(content) == transactionDate {
@@ -129,3 +131,63 @@ func TestLexTransactionHeader(t *testing.T) {
 
 func TestLexTransactionHeaderWithComment(t *testing.T) {
 }
+
+func TestLexLongLines(t *testing.T) {
+	receipt := strings.Repeat("QUJD", 50000)
+	src := "2020-10-11 A shop  ; receipt:" + receipt + "\n" +
+		"    Expenses:Food    £10  ; " + receipt + "\r\n" +
+		"    Assets:Current\n"
+
+	var parsed *journal.Transaction
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		parsed = t
+		return nil
+	}, nil)
+	if err := p.Parse(strings.NewReader(src), "test.journal"); err != nil {
+		t.Fatalf("parsing a long line failed: %s", err)
+	}
+	if parsed == nil || parsed.HeaderNote != "receipt:"+receipt {
+		t.Fatalf("the long note was not kept")
+	}
+	if len(parsed.Postings) != 2 || parsed.Postings[1].Line != 3 {
+		t.Fatalf("lines after a long line are counted wrongly")
+	}
+}
+
+func TestLexOddLinesReturnErrors(t *testing.T) {
+	lines := []string{
+		"2", "2020", "2020-", "~", "~ ", "=", "i", "include", "include ",
+		"2020-10-11\n  A", "2020-10-11\n  A  ", "2020-10-11\n  A  =", "2020-10-11\n  A  £", "2020-10-11\n  A  -",
+		"2020-10-11 *", "2020-10-11 !\n\tA\t=\t", "2020-10-11\n  ;", "~ every\n  A  £1", "\t", "  ", " ",
+		"2020-10-11\n  A  £1=", "2020-10-11\n  A  £1 = £", "account", "commodity  £",
+	}
+	for _, line := range lines {
+		for _, collect := range []bool{false, true} {
+			p := NewParser(nil, nil)
+			if collect {
+				p.CollectErrors(0)
+			}
+			// Errors are fine but the parser must not panic or exit
+			p.Parse(strings.NewReader(line), "test.journal")
+			p.ParseTree(ParseSyntax([]byte(line), "test.journal"))
+		}
+	}
+}
+
+func TestLexingLeavesLineUnchanged(t *testing.T) {
+	line := "    Expenses:Food  £\t10 ; lunch"
+	p := NewParser(nil, nil)
+	p.lexer = &lexer{input: []byte(line), parser: p.parseItem}
+	p.beginTransaction(normalTransaction)
+	p.transactionBuilder.previousItemType = payeeItem
+	p.lexer.next()
+	if err := p.lexer.lexPosting(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(p.lexer.input) != line {
+		t.Fatalf("lexing changed the line to %q", p.lexer.input)
+	}
+	if amount := p.transactionBuilder.currentPosting.Amount; amount.Commodity != "£ " || amount.Quantity != 1000 {
+		t.Fatalf("expected £ 10.00, got %v", amount)
+	}
+}
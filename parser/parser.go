@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/rikchilvers/gledger/journal"
 )
@@ -15,15 +19,22 @@ import (
 type (
 	TransactionHandler         = func(t *journal.Transaction, path string) error
 	PeriodicTransactionHandler = func(t *journal.PeriodicTransaction, path string) error
-	itemParser                 = func(t itemType, content []rune) error
+	DeclarationHandler         = func(d journal.Declaration, path string) error
+	itemParser                 = func(t itemType, content []byte) error
 )
 
 // Parser is how gledger reads journal files
 type Parser struct {
 	transactionHandler         TransactionHandler
 	periodicTransactionHandler PeriodicTransactionHandler
+	declarationHandler         DeclarationHandler
 	transactionBuilder         transactionBuilder
 	journalFiles               []string
+	lexer                      *lexer      // the lexer of the file being parsed
+	collectErrors              bool        // whether to carry on after errors
+	maxErrors                  int         // how many errors to collect before stopping
+	diagnostics                Diagnostics // the errors collected so far
+	skipIncludes               bool        // whether to ignore include directives
 }
 
 // NewParser creates a parser (including its journal)
@@ -36,112 +47,322 @@ func NewParser(th TransactionHandler, ph PeriodicTransactionHandler) Parser {
 	}
 }
 
-// Parse lexes and parses the provided file line by line
+// HandleDeclarations passes account, commodity, payee and tag declarations to h
+func (p *Parser) HandleDeclarations(h DeclarationHandler) {
+	p.declarationHandler = h
+}
+
+// Parse lexes and parses the provided file line by line.
+// Errors are returned as a *Diagnostic, or as Diagnostics when collecting errors.
+// Parse can be called for each of several files: when collecting errors it returns those from every file so far
+// (and stops reading once there are too many).
 func (p *Parser) Parse(reader io.Reader, locationHint string) error {
+	if p.collectErrors && p.maxErrors > 0 && len(p.diagnostics) >= p.maxErrors {
+		return p.diagnostics
+	}
+
 	p.journalFiles = append(p.journalFiles, locationHint)
+	defer func() { p.journalFiles = p.journalFiles[:len(p.journalFiles)-1] }()
 
 	// Begin lexing
-	lexer := newLexer(reader, locationHint, p.parseItem)
-	if err := lexer.lex(); err != nil {
-		// This is the exit point for the lexer's errors
-		return fmt.Errorf("error at %w", err)
+	if err := p.lex(reader, locationHint); err != nil {
+		return err
+	}
+
+	if len(p.diagnostics) > 0 {
+		return p.diagnostics
+	}
+	return nil
+}
+
+// lex lexes a file, keeping track of its lexer so errors can say where they were found
+func (p *Parser) lex(reader io.Reader, path string) error {
+	lexer := newLexer(reader, path, p.parseItem)
+	if p.collectErrors {
+		lexer.recover = p.recoverFrom
 	}
 
+	previous := p.lexer
+	p.lexer = &lexer
+	defer func() { p.lexer = previous }()
+
+	// This is the exit point for the lexer's errors
+	return lexer.lex()
+}
+
+// endTransaction closes the transaction being built.
+// Errors say where the transaction started rather than where it was closed.
+func (p *Parser) endTransaction() error {
+	tb := p.transactionBuilder
+	if err := p.transactionBuilder.endTransaction(*p); err != nil {
+		source := string(tb.source)
+		d := newDiagnostic(tb.path, tb.line, source, 1, utf8.RuneCountInString(source)+1, err)
+		if p.collectErrors {
+			return p.recordError(d)
+		}
+		p.transactionBuilder.abandon()
+		return d
+	}
 	return nil
 }
 
-func (p *Parser) parseItem(t itemType, content []rune) error {
+// beginTransaction starts building a transaction on the line being lexed
+func (p *Parser) beginTransaction(t transactionType) {
+	tb := &p.transactionBuilder
+	tb.beginTransaction(t)
+	if p.lexer == nil {
+		return
+	}
+
+	tb.path = p.lexer.locationHint
+	tb.line = p.lexer.currentLine
+	tb.source = append(tb.source[:0], p.lexer.input...)
+	switch t {
+	case normalTransaction:
+		tb.transaction.Path, tb.transaction.Line = tb.path, tb.line
+	case periodicTransaction:
+		tb.periodicTransaction.Transaction.Path, tb.periodicTransaction.Transaction.Line = tb.path, tb.line
+	}
+}
+
+func (p *Parser) parseItem(t itemType, content []byte) error {
 	switch t {
 	case emptyLineItem:
 		// an empty line signals that the transaction should close
-		if err := p.transactionBuilder.endTransaction(*p); err != nil {
+		if err := p.endTransaction(); err != nil {
 			return err
 		}
 	case eofItem: // Make sure we close the final transaction
-		if err := p.transactionBuilder.endTransaction(*p); err != nil {
+		if err := p.endTransaction(); err != nil {
 			return err
 		}
 	case includeItem:
-		path := string(content)
-
-		// Add the file to the slice
-		p.journalFiles = append(p.journalFiles, path)
-
-		// Open the file
-		file, err := os.Open(path)
-		if err != nil {
+		// Includes end any transaction before them so it is reported from the right file
+		if err := p.endTransaction(); err != nil {
 			return err
 		}
-		defer file.Close()
-
-		// Lex the file
-		lexer := newLexer(file, path, p.parseItem)
-		if err := lexer.lex(); err != nil {
-			// This is the exit point for the lexer's errors
-			return fmt.Errorf("error at %w", err)
+		if p.skipIncludes {
+			return nil
 		}
-
-		// Drop the file from the slice
-		p.journalFiles = p.journalFiles[:1]
+		return p.include(string(content))
 	case dateItem:
 		// This will start a transaction so check if we need to close a previous one
 		// in case there is no empty line between transactions
-		if err := p.transactionBuilder.endTransaction(*p); err != nil {
+		if err := p.endTransaction(); err != nil {
 			return err
 		}
 
-		p.transactionBuilder.beginTransaction(normalTransaction)
+		p.beginTransaction(normalTransaction)
 
 		// tell the builder about this date
 		if err := p.transactionBuilder.build(t, content); err != nil {
-			return fmt.Errorf("error parsing date\n%w", err)
+			return err
 		}
 	case periodItem:
 		// This will start a transaction so check if we need to close a previous one
 		// in case there is no empty line between transactions
-		if err := p.transactionBuilder.endTransaction(*p); err != nil {
+		if err := p.endTransaction(); err != nil {
 			return err
 		}
 
-		p.transactionBuilder.beginTransaction(periodicTransaction)
+		p.beginTransaction(periodicTransaction)
 		if err := p.transactionBuilder.build(t, content); err != nil {
-			return fmt.Errorf("error parsing period\n%w", err)
+			return err
 		}
+	case directiveItem:
+		// Directives end any transaction before them
+		if err := p.endTransaction(); err != nil {
+			return err
+		}
+		return p.parseDirective(content)
 	default:
-		return p.transactionBuilder.build(t, content)
+		if err := p.transactionBuilder.build(t, content); err != nil {
+			return err
+		}
+		if t == accountItem && p.lexer != nil {
+			p.transactionBuilder.currentPosting.Line = p.lexer.currentLine
+		}
 	}
 
 	return nil
 }
 
-func parseDate(content []rune) (time.Time, error) {
-	const dashDateFormat string = "2006-01-02"
-	const dotdateItemFormat string = "2006.01.02"
-	const SlashDateFormat string = "2006/01/02"
+// declarationDirectives maps the directives which declare things to what they declare
+var declarationDirectives = map[string]journal.DeclarationType{
+	"account":   journal.AccountDeclaration,
+	"commodity": journal.CommodityDeclaration,
+	"payee":     journal.PayeeDeclaration,
+	"tag":       journal.TagDeclaration,
+}
+
+// parseDirective parses a directive other than include
+func (p *Parser) parseDirective(content []byte) error {
+	fields := strings.SplitN(string(content), " ", 2)
+	directive, argument := fields[0], ""
+	if len(fields) > 1 {
+		argument = strings.TrimSpace(fields[1])
+	}
 
-	s := string(content)
-	var date time.Time
-	var err error
+	switch {
+	case isYearDirective(directive):
+		return p.parseYearDirective(directive, argument)
+	case directive == "D":
+		return p.parseDefaultCommodityDirective(argument)
+	}
 
-	switch content[4] {
-	case '-':
-		date, err = time.Parse(dashDateFormat, s)
-	case '.':
-		date, err = time.Parse(dotdateItemFormat, s)
-	case '/':
-		date, err = time.Parse(SlashDateFormat, s)
-	default:
-		return time.Time{}, fmt.Errorf("date is malformed: %s", s)
+	declarationType, found := declarationDirectives[directive]
+	if !found {
+		return withHint(fmt.Errorf("unknown directive: %s", directive), "directives are account, commodity, D, include, payee, tag and year (postings must be indented)")
+	}
+	if len(argument) == 0 {
+		return withHint(fmt.Errorf("%s directive is missing a name", directive), fmt.Sprintf("declare one with '%s NAME'", directive))
 	}
 
+	// Commodities can be declared with an example amount, such as £1,000.00
+	if declarationType == journal.CommodityDeclaration {
+		if amount, err := ParseCommodityAmount(argument); err == nil && len(amount.Commodity) > 0 {
+			argument = amount.Commodity
+		}
+	}
+
+	return p.declare(declarationType, argument)
+}
+
+// isYearDirective reports whether a directive sets the year of dates without one
+func isYearDirective(directive string) bool {
+	return directive == "Y" || directive == "year"
+}
+
+// parseYearDirective sets the year of the dates without one which follow it, as in 'year 2020'
+func (p *Parser) parseYearDirective(directive, argument string) error {
+	year, err := parseYearArgument(argument)
 	if err != nil {
-		return time.Time{}, err
+		return withHint(err, fmt.Sprintf("set the year of dates such as 10/31 with '%s 2020'", directive))
 	}
+	p.transactionBuilder.year = year
+	return nil
+}
 
+// parseYearArgument parses the argument of a year directive
+func parseYearArgument(argument string) (int, error) {
+	if len(argument) == 0 {
+		return 0, errors.New("year directive is missing a year")
+	}
+	date, err := parseYear(argument)
+	if err != nil {
+		return 0, fmt.Errorf("invalid year: %s", argument)
+	}
+	return date.Year(), nil
+}
+
+// parseDefaultCommodityDirective sets the commodity of the amounts without one which follow it, as in 'D £1,000.00'.
+// The commodity is declared too.
+func (p *Parser) parseDefaultCommodityDirective(argument string) error {
+	amount, err := ParseCommodityAmount(argument)
+	if err != nil || len(amount.Commodity) == 0 {
+		return withHint(fmt.Errorf("invalid default commodity: %s", argument), "set the commodity of amounts without one with 'D £1,000.00'")
+	}
+	p.transactionBuilder.defaultCommodity = amount.Commodity
+	return p.declare(journal.CommodityDeclaration, amount.Commodity)
+}
+
+// declare passes a declaration to the declaration handler
+func (p *Parser) declare(declarationType journal.DeclarationType, name string) error {
+	if p.declarationHandler == nil {
+		return nil
+	}
+	d := journal.Declaration{Type: declarationType, Name: name}
+	if p.lexer != nil {
+		d.Line = p.lexer.currentLine
+	}
+	return p.declarationHandler(d, p.journalFiles[len(p.journalFiles)-1])
+}
+
+// parseDateInYear parses a date, giving dates without a year (such as 10/31) the year provided.
+// Dates without a year are in the current year if no year is provided.
+func parseDateInYear(content []byte, year int) (time.Time, error) {
+	// Dates without a year are at most five characters long, such as 10/31
+	if len(content) > 5 {
+		return parseDate(content)
+	}
+	month, day, found := splitDateWithoutYear(string(content))
+	if !found {
+		return parseDate(content)
+	}
+
+	if year == 0 {
+		year = time.Now().Year()
+	}
+	return time.Parse("2006-1-2", fmt.Sprintf("%d-%s-%s", year, month, day))
+}
+
+// splitDateWithoutYear splits a date such as 10/31 into its month and day
+func splitDateWithoutYear(date string) (month, day string, found bool) {
+	separator := strings.IndexAny(date, "-/.")
+	if separator < 1 || separator > 2 || strings.Count(date, date[separator:separator+1]) != 1 {
+		return "", "", false
+	}
+	return date[:separator], date[separator+1:], true
+}
+
+// parseDate parses a date such as 2020-10-31, 2020/10/31 or 2020.10.31.
+// As every transaction has a date, the digits are read here rather than with time.Parse to save converting them to a string.
+func parseDate(content []byte) (time.Time, error) {
+	if len(content) != 10 || content[7] != content[4] || (content[4] != '-' && content[4] != '/' && content[4] != '.') {
+		return time.Time{}, fmt.Errorf("date is malformed: %s", content)
+	}
+
+	year, yearFound := parseDigits(content[:4])
+	month, monthFound := parseDigits(content[5:7])
+	day, dayFound := parseDigits(content[8:])
+	if !yearFound || !monthFound || !dayFound || month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("date is malformed: %s", content)
+	}
+
+	// Days past the end of the month are not rolled over into the next month
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if day < 1 || date.Month() != time.Month(month) {
+		return time.Time{}, fmt.Errorf("day out of range: %s", content)
+	}
 	return date, nil
 }
 
-func parseAmount(content []rune) (int64, error) {
+// parseDigits parses a number made only of digits
+func parseDigits(digits []byte) (int, bool) {
+	n := 0
+	for _, b := range digits {
+		if !isDigit(b) {
+			return 0, false
+		}
+		n = n*10 + int(b-'0')
+	}
+	return n, len(digits) > 0
+}
+
+// ParseCommodityAmount parses an amount with an optional leading commodity, such as £1,000.00
+func ParseCommodityAmount(s string) (journal.Amount, error) {
+	b := []byte(strings.ReplaceAll(s, ",", ""))
+
+	i := bytes.IndexFunc(b, func(r rune) bool {
+		return unicode.IsNumber(r) || r == '-' || r == '+'
+	})
+	if i == -1 {
+		return journal.Amount{}, fmt.Errorf("amount is malformed: %s", s)
+	}
+
+	quantity, err := parseAmount(b[i:])
+	if err != nil {
+		return journal.Amount{}, fmt.Errorf("error parsing amount: %w", err)
+	}
+
+	return *journal.NewAmount(strings.TrimSpace(string(b[:i])), quantity), nil
+}
+
+func parseAmount(content []byte) (int64, error) {
+	if len(content) == 0 {
+		return 0, errors.New("missing amount")
+	}
+
 	// Handle signs
 	firstRune := content[0]
 	var multiplier int64
@@ -192,21 +413,94 @@ func parseAmount(content []rune) (int64, error) {
 	return quantity, nil
 }
 
-func parsePeriod(content []rune) (journal.Period, error) {
+// periodIntervals maps the words for an interval to its type
+var periodIntervals = map[string]journal.PeriodType{
+	"daily":       journal.PDaily,
+	"weekly":      journal.PWeekly,
+	"biweekly":    journal.PBiweekly,
+	"fortnightly": journal.PFortnightly,
+	"monthly":     journal.PMonthly,
+	"bimonthly":   journal.PBiMonthly,
+	"quarterly":   journal.PQuarterly,
+	"yearly":      journal.PYearly,
+	"annually":    journal.PYearly,
+}
+
+// periodUnits maps the units used in 'every N units' to an interval type
+var periodUnits = map[string]journal.PeriodType{
+	"day":     journal.PDaily,
+	"week":    journal.PWeekly,
+	"month":   journal.PMonthly,
+	"quarter": journal.PQuarterly,
+	"year":    journal.PYearly,
+}
+
+// parsePeriod parses the period of a periodic transaction.
+// Budget periods are a single month (2020-06).
+// Other periods have an interval (monthly, every 2 weeks) optionally followed by 'from DATE' and 'to DATE'.
+func parsePeriod(content []byte) (journal.Period, error) {
 	const budgetDateFormat string = "2006-01"
 
 	p := journal.Period{}
 	s := string(content)
 
 	// Try to cast to a budget date
-	date, err := time.Parse(budgetDateFormat, s)
-	if err != nil {
+	if date, err := time.Parse(budgetDateFormat, s); err == nil {
+		p.StartDate = date
+		p.EndDate = date
+		p.Interval = journal.PNone
 		return p, nil
 	}
 
-	p.StartDate = date
-	p.EndDate = date
-	p.Interval = journal.PNone
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return p, errors.New("periodic transaction is missing a period")
+	}
+
+	// Parse the interval
+	if interval, found := periodIntervals[fields[0]]; found {
+		p.Interval = interval
+		p.IntervalFrequency = 1
+		fields = fields[1:]
+	} else if fields[0] == "every" && len(fields) > 1 {
+		frequency := 1
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			frequency = n
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			return p, fmt.Errorf("period is malformed: %s", s)
+		}
+		interval, found := periodUnits[strings.TrimSuffix(fields[1], "s")]
+		if !found || frequency < 1 {
+			return p, fmt.Errorf("period is malformed: %s", s)
+		}
+		p.Interval = interval
+		p.IntervalFrequency = frequency
+		fields = fields[2:]
+	} else {
+		return p, fmt.Errorf("unknown period: %s", s)
+	}
+
+	// Parse the bounds
+	for len(fields) > 0 {
+		if len(fields) < 2 {
+			return p, fmt.Errorf("period is malformed: %s", s)
+		}
+		date, err := ParseSmartDate(fields[1])
+		if err != nil {
+			return p, fmt.Errorf("error parsing period date: %w", err)
+		}
+		switch fields[0] {
+		case "from":
+			p.StartDate = date
+		case "to", "until":
+			p.EndDate = date
+		default:
+			return p, fmt.Errorf("period is malformed: %s", s)
+		}
+		fields = fields[2:]
+	}
 
 	return p, nil
 }
@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected journal.Period
+	}{
+		{"2020-06", journal.Period{
+			StartDate: time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+			Interval:  journal.PNone,
+		}},
+		{"monthly", journal.Period{Interval: journal.PMonthly, IntervalFrequency: 1}},
+		{"every 2 weeks", journal.Period{Interval: journal.PWeekly, IntervalFrequency: 2}},
+		{"every day", journal.Period{Interval: journal.PDaily, IntervalFrequency: 1}},
+		{"Quarterly from 2020-01 to 2021", journal.Period{
+			StartDate:         time.Date(2020, time.January, 1, 0, 0, 0, 0, time.Local),
+			EndDate:           time.Date(2021, time.January, 1, 0, 0, 0, 0, time.Local),
+			Interval:          journal.PQuarterly,
+			IntervalFrequency: 1,
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := parsePeriod([]byte(test.input))
+		if err != nil {
+			t.Fatalf("failed to parse period '%s': %s", test.input, err)
+		}
+		if got != test.expected {
+			t.Fatalf("parsing '%s'\nexpected\t%+v\ngot\t\t%+v", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParsePeriodMalformed(t *testing.T) {
+	for _, input := range []string{"", "sometimes", "every", "every 2", "every fortnight", "monthly from", "monthly since 2020"} {
+		if _, err := parsePeriod([]byte(input)); err == nil {
+			t.Fatalf("should have errored for period '%s'", input)
+		}
+	}
+}
+
+func TestParseAssertionsAndDeclarations(t *testing.T) {
+	const input = `account Assets:Current
+commodity £1,000.00
+payee Shop
+
+2020-10-01 Shop
+    Expenses:Food    £10 = £10
+    Assets:Current  = £-10
+`
+
+	var transactions []*journal.Transaction
+	var declarations []journal.Declaration
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		transactions = append(transactions, t)
+		return nil
+	}, nil)
+	p.HandleDeclarations(func(d journal.Declaration, _ string) error {
+		declarations = append(declarations, d)
+		return nil
+	})
+	if err := p.Parse(strings.NewReader(input), "test.journal"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []journal.Declaration{
+		{Type: journal.AccountDeclaration, Name: "Assets:Current", Line: 1},
+		{Type: journal.CommodityDeclaration, Name: "£", Line: 2},
+		{Type: journal.PayeeDeclaration, Name: "Shop", Line: 3},
+	}
+	if len(declarations) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, declarations)
+	}
+	for i := range expected {
+		if declarations[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], declarations[i])
+		}
+	}
+
+	if len(transactions) != 1 || transactions[0].Line != 5 {
+		t.Fatalf("expected a transaction on line 5")
+	}
+	food, current := transactions[0].Postings[0], transactions[0].Postings[1]
+	if food.Line != 6 || food.Amount.Quantity != 1000 || food.Assertion == nil || food.Assertion.Quantity != 1000 {
+		t.Fatalf("food posting was not parsed with its assertion")
+	}
+	if current.Line != 7 || current.Amount.Quantity != -1000 || current.Assertion == nil || current.Assertion.Quantity != -1000 {
+		t.Fatalf("elided posting was not parsed with its assertion")
+	}
+}
+
+func TestParseSeveralFiles(t *testing.T) {
+	found := make([]string, 0)
+	p := NewParser(func(t *journal.Transaction, path string) error {
+		found = append(found, fmt.Sprintf("%s %s:%d", t.Payee, path, t.Line))
+		if t.Path != path {
+			return fmt.Errorf("transaction has path %s rather than %s", t.Path, path)
+		}
+		return nil
+	}, nil)
+	p.CollectErrors(0)
+
+	personal := "2020-01-01 Personal\n    Expenses:Food  £1\n    Assets:Current\n\n2020-01-0x Broken\n"
+	joint := "\n2020-01-02 Joint\n    Expenses:Food  £5\n    Assets:Joint\n\n2020-01-0y Broken\n"
+	p.Parse(strings.NewReader(personal), "personal.journal")
+	err := p.Parse(strings.NewReader(joint), "joint.journal")
+
+	if strings.Join(found, ", ") != "Personal personal.journal:1, Joint joint.journal:2" {
+		t.Fatalf("transactions came from the wrong files: %v", found)
+	}
+
+	// Errors from every file are returned
+	var collected Diagnostics
+	if !errors.As(err, &collected) || len(collected) != 2 || collected[0].Path != "personal.journal" || collected[1].Path != "joint.journal" {
+		t.Fatalf("expected an error from each file, got %v", err)
+	}
+}
+
+func TestParseYearAndDefaultCommodityDirectives(t *testing.T) {
+	const input = `Y 2019
+D £1,000.00
+
+12/31 Shop
+    Expenses:Food    10 = 10
+    Assets:Current
+
+year 2020
+01/02 Shop
+    Expenses:Food    $5
+    Assets:Current
+
+2021-01-03 Shop
+    Expenses:Food    7.50
+    Assets:Current
+`
+
+	var transactions []*journal.Transaction
+	var declarations []journal.Declaration
+	p := NewParser(func(t *journal.Transaction, _ string) error {
+		transactions = append(transactions, t)
+		return nil
+	}, nil)
+	p.HandleDeclarations(func(d journal.Declaration, _ string) error {
+		declarations = append(declarations, d)
+		return nil
+	})
+	if err := p.Parse(strings.NewReader(input), "test.journal"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The default commodity is declared
+	if len(declarations) != 1 || declarations[0].Type != journal.CommodityDeclaration || declarations[0].Name != "£" {
+		t.Fatalf("expected the default commodity to be declared, got %v", declarations)
+	}
+
+	expected := []struct {
+		date      string
+		commodity string
+		quantity  int64
+	}{
+		{"2019-12-31", "£", 1000},
+		{"2020-01-02", "$", 500},
+		{"2021-01-03", "£", 750},
+	}
+	if len(transactions) != len(expected) {
+		t.Fatalf("expected %d transactions, got %d", len(expected), len(transactions))
+	}
+	for i, e := range expected {
+		tr := transactions[i]
+		amount := tr.Postings[0].Amount
+		if tr.Date.Format("2006-01-02") != e.date || amount.Commodity != e.commodity || amount.Quantity != e.quantity {
+			t.Errorf("expected %s %s%d, got %s %s%d", e.date, e.commodity, e.quantity, tr.Date.Format("2006-01-02"), amount.Commodity, amount.Quantity)
+		}
+	}
+	if assertion := transactions[0].Postings[0].Assertion; assertion == nil || assertion.Commodity != "£" {
+		t.Errorf("expected the assertion to be in the default commodity")
+	}
+}
+
+func TestParseDirectivesSeparatedByTabs(t *testing.T) {
+	const input = "account\tAssets:Current\npayee  Shop ; regular\ntag\t\n"
+
+	for _, parse := range []func(p *Parser) error{
+		func(p *Parser) error { return p.Parse(strings.NewReader(input), "test.journal") },
+		func(p *Parser) error { return p.ParseTree(ParseSyntax([]byte(input), "test.journal")) },
+	} {
+		var declarations []journal.Declaration
+		p := NewParser(nil, nil)
+		p.HandleDeclarations(func(d journal.Declaration, _ string) error {
+			declarations = append(declarations, d)
+			return nil
+		})
+		err := parse(&p)
+
+		expected := []journal.Declaration{
+			{Type: journal.AccountDeclaration, Name: "Assets:Current", Line: 1},
+			{Type: journal.PayeeDeclaration, Name: "Shop", Line: 2},
+		}
+		if len(declarations) != len(expected) || declarations[0] != expected[0] || declarations[1] != expected[1] {
+			t.Fatalf("expected %v, got %v", expected, declarations)
+		}
+
+		var d *Diagnostic
+		if !errors.As(err, &d) || d.Line != 3 || d.Column != 1 || d.EndColumn != 4 {
+			t.Fatalf("expected the tag directive without a name to be reported, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is where something is in a journal's source: the bytes from Start up to (but not including) End
+type Span struct {
+	Start int
+	End   int
+}
+
+// TokenKind is what a token of a journal's source is
+type TokenKind int
+
+// The kinds of token.
+// Whitespace, newlines, comments and ignored text are trivia: changing them does not change what the journal means
+// (other than the notes and comments of transactions).
+const (
+	WhitespaceToken TokenKind = iota // spaces and tabs
+	NewlineToken                     // the end of a line (\n or \r\n)
+	CommentToken                     // a comment, including its ; or #
+	TextToken                        // text the parser ignores
+	DateToken
+	StateToken // * or !
+	PayeeToken
+	NoteToken  // a note on a transaction header written without a comment indicator
+	TildeToken // the ~ starting a periodic transaction
+	PeriodToken
+	AccountToken
+	CommodityToken
+	AmountToken
+	EqualsToken    // the = starting a balance assertion
+	AssertionToken // the amount a balance assertion asserts
+	DirectiveToken // the name of a directive, such as account or include
+	ArgumentToken  // what a directive declares or includes
+)
+
+// Token is a piece of a journal's source.
+// Payees, periods, commodities and amounts can be empty.
+type Token struct {
+	Kind TokenKind
+	Span Span
+	Text string
+}
+
+// IsTrivia reports whether the token is whitespace, a newline, a comment or text the parser ignores
+func (t Token) IsTrivia() bool {
+	switch t.Kind {
+	case WhitespaceToken, NewlineToken, CommentToken, TextToken:
+		return true
+	default:
+		return false
+	}
+}
+
+// NodeKind is what a line of a journal is
+type NodeKind int
+
+// The kinds of node
+const (
+	BlankNode               NodeKind = iota // an empty line, which ends a transaction
+	CommentNode                             // a comment on its own line
+	DirectiveNode                           // a directive such as account or include
+	TransactionNode                         // a transaction header
+	PeriodicTransactionNode                 // a periodic transaction header
+	PostingNode                             // a posting
+	TextNode                                // a line the parser ignores or cannot read
+)
+
+// SyntaxNode is a line of a journal.
+// Transactions also hold the lines beneath their header until the transaction ends.
+type SyntaxNode struct {
+	Kind        NodeKind
+	Line        int           // the line the node starts on (the first line is 1)
+	Span        Span          // every byte of the node, including its children
+	Tokens      []Token       // the tokens of the node's first line, ending with its newline (if it has one)
+	Children    []*SyntaxNode // the postings, comments and other lines of a transaction
+	problem     error         // what the parser reports about the line
+	problemSpan Span
+}
+
+// SyntaxTree is a journal file as it was written, keeping every byte so that it can be edited without losing anything.
+// Its transactions and declarations can be read with Parser.ParseTree.
+type SyntaxTree struct {
+	Path  string
+	Nodes []*SyntaxNode
+}
+
+// ParseSyntax splits a journal's source into a syntax tree.
+// It does not fail: lines the parser cannot read are kept as they are and reported by Parser.ParseTree.
+func ParseSyntax(src []byte, path string) *SyntaxTree {
+	source := string(src)
+	tree := &SyntaxTree{Path: path, Nodes: make([]*SyntaxNode, 0, strings.Count(source, "\n")/2+1)}
+
+	var transaction *SyntaxNode
+	line := 1
+	for start := 0; start < len(source); line++ {
+		end, next := len(source), len(source)
+		if i := strings.IndexByte(source[start:], '\n'); i >= 0 {
+			end, next = start+i, start+i+1
+			if end > start && source[end-1] == '\r' {
+				end--
+			}
+		}
+
+		n := scanLine(src[start:end], source[start:end], start, line)
+		if end < next {
+			n.Tokens = append(n.Tokens, Token{Kind: NewlineToken, Span: Span{end, next}, Text: source[end:next]})
+		}
+		n.Span = Span{start, next}
+
+		// Transactions run until an empty line, a directive or the next transaction
+		switch n.Kind {
+		case TransactionNode, PeriodicTransactionNode:
+			transaction = n
+			tree.Nodes = append(tree.Nodes, n)
+		case BlankNode, DirectiveNode:
+			transaction = nil
+			tree.Nodes = append(tree.Nodes, n)
+		default:
+			if transaction == nil {
+				tree.Nodes = append(tree.Nodes, n)
+			} else {
+				transaction.Children = append(transaction.Children, n)
+				transaction.Span.End = next
+			}
+		}
+
+		start = next
+	}
+
+	return tree
+}
+
+// Bytes writes the tree back out. A tree which has not been changed gives back its source exactly.
+func (t *SyntaxTree) Bytes() []byte {
+	var b strings.Builder
+	for _, n := range t.Nodes {
+		n.write(&b)
+	}
+	return []byte(b.String())
+}
+
+// Bytes writes the node (and its children) back out
+func (n *SyntaxNode) Bytes() []byte {
+	var b strings.Builder
+	n.write(&b)
+	return []byte(b.String())
+}
+
+func (n *SyntaxNode) write(b *strings.Builder) {
+	for _, t := range n.Tokens {
+		b.WriteString(t.Text)
+	}
+	for _, c := range n.Children {
+		c.write(b)
+	}
+}
+
+// Text is the node's first line without its newline
+func (n *SyntaxNode) Text() string {
+	var b strings.Builder
+	for _, t := range n.Tokens {
+		if t.Kind != NewlineToken {
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+// Token finds the first token of a kind on the node's first line
+func (n *SyntaxNode) Token(kind TokenKind) (Token, bool) {
+	for _, t := range n.Tokens {
+		if t.Kind == kind {
+			return t, true
+		}
+	}
+	return Token{}, false
+}
+
+// report records what the parser says is wrong with the line
+func (n *SyntaxNode) report(span Span, err error) {
+	n.problem = err
+	n.problemSpan = span
+}
+
+// lineScanner splits a line into tokens with the same functions as the lexer
+type lineScanner struct {
+	line   []byte // the line without its newline
+	text   string // the line as a string, which the tokens' text is taken from
+	offset int    // where the line starts in the source
+	pos    int
+	tokens []Token
+}
+
+// scanLine creates the node for a line of a journal
+func scanLine(src []byte, text string, offset, line int) *SyntaxNode {
+	n := &SyntaxNode{Line: line}
+	s := lineScanner{line: src, text: text, offset: offset}
+	switch classifyLine(src) {
+	case blankLine:
+		n.Kind = BlankNode
+		return n
+	case commentLine:
+		n.Kind = CommentNode
+		s.emit(CommentToken, len(text))
+	case directiveLine:
+		n.Kind = DirectiveNode
+		s.scanDirective(n)
+	case periodicHeaderLine:
+		n.Kind = PeriodicTransactionNode
+		s.scanPeriodHeader(n)
+	case headerLine:
+		n.Kind = TransactionNode
+		s.scanHeader()
+	case postingLine:
+		s.scanPosting(n)
+	default:
+		n.Kind = TextNode
+		s.emit(TextToken, len(text))
+		n.report(s.span(0, len(text)), withHint(errors.New("unexpected line"), "transactions start with a date and their postings are indented by a tab or at least two spaces"))
+	}
+
+	n.Tokens = s.tokens
+	return n
+}
+
+// span turns positions in the line into a Span
+func (s *lineScanner) span(start, end int) Span {
+	return Span{s.offset + start, s.offset + end}
+}
+
+// emit adds a token for the text from the scanner's position up to end, unless there is no text
+func (s *lineScanner) emit(kind TokenKind, end int) {
+	if end > s.pos {
+		s.emitValue(kind, end)
+	}
+}
+
+// emitValue adds a token for the text from the scanner's position up to end, even if there is no text
+func (s *lineScanner) emitValue(kind TokenKind, end int) {
+	s.tokens = append(s.tokens, Token{Kind: kind, Span: s.span(s.pos, end), Text: s.text[s.pos:end]})
+	s.pos = end
+}
+
+// rest adds the rest of the line, which the parser ignores
+func (s *lineScanner) rest() {
+	end, _ := spaceEnd(s.line, s.pos)
+	s.emit(WhitespaceToken, end)
+	if s.pos == len(s.text) {
+		return
+	}
+
+	kind := TextToken
+	if r, _ := utf8.DecodeRuneInString(s.text[s.pos:]); isCommentIndicator(r) {
+		kind = CommentToken
+	}
+	s.emit(kind, len(s.text))
+}
+
+// scanDirective splits a directive into its name and argument
+func (s *lineScanner) scanDirective(n *SyntaxNode) {
+	name := directiveNameEnd(s.line)
+	s.emit(DirectiveToken, name)
+	end, count := spaceEnd(s.line, s.pos)
+
+	if s.text[:name] == "include" {
+		s.emit(WhitespaceToken, end)
+		problem := s.span(0, s.pos)
+		file, next := fieldEnd(s.line, s.pos)
+		if count == 0 || file == s.pos {
+			if count > 0 {
+				problem = s.span(s.pos, next)
+			}
+			n.report(problem, withHint(errors.New("include is missing a file"), "include a file with 'include path/to/file.journal'"))
+			s.rest()
+			return
+		}
+		s.emit(ArgumentToken, file)
+		s.rest()
+		return
+	}
+
+	// Other directives are a name and (after some whitespace) an argument
+	if argument, _ := fieldEnd(s.line, end); argument > end {
+		s.emit(WhitespaceToken, end)
+		s.emit(ArgumentToken, argument)
+	}
+	s.rest()
+}
+
+// scanPeriodHeader splits a periodic transaction header into its period and anything after it
+func (s *lineScanner) scanPeriodHeader(n *SyntaxNode) {
+	s.emit(TildeToken, 1)
+	end, count := spaceEnd(s.line, s.pos)
+	s.emit(WhitespaceToken, end)
+	if count == 0 {
+		n.report(s.span(0, s.pos), withHint(errors.New("periodic transaction is missing a period"), "put a space between ~ and the period, as in '~ 2020-06' or '~ monthly'"))
+		s.rest()
+		return
+	}
+
+	period, _ := fieldEnd(s.line, s.pos)
+	s.emitValue(PeriodToken, period)
+	s.rest()
+}
+
+// scanHeader splits a transaction header into its date, state, payee and note
+func (s *lineScanner) scanHeader() {
+	s.emit(DateToken, wordEnd(s.line, 0))
+	end, _ := spaceEnd(s.line, s.pos)
+	s.emit(WhitespaceToken, end)
+
+	if s.pos < len(s.text) && (s.text[s.pos] == '*' || s.text[s.pos] == '!') {
+		s.emit(StateToken, s.pos+1)
+		end, _ := spaceEnd(s.line, s.pos)
+		s.emit(WhitespaceToken, end)
+	}
+
+	payee, _ := fieldEnd(s.line, s.pos)
+	s.emitValue(PayeeToken, payee)
+
+	// Everything after the payee is the note, whether or not it starts with a comment indicator
+	note := s.pos
+	for note < len(s.text) && (s.text[note] == ' ' || s.text[note] == '\t') {
+		note++
+	}
+	s.emit(WhitespaceToken, note)
+	if s.pos == len(s.text) {
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(s.text[s.pos:]); isCommentIndicator(r) {
+		s.emit(CommentToken, len(s.text))
+	} else {
+		s.emit(NoteToken, len(s.text))
+	}
+}
+
+// scanPosting splits a posting into its account, commodity, amount and balance assertion.
+// Indented comments and lines which are not postings are kept whole.
+func (s *lineScanner) scanPosting(n *SyntaxNode) {
+	end, _ := spaceEnd(s.line, 0)
+	s.emit(WhitespaceToken, end)
+	if s.pos == len(s.text) {
+		n.Kind = TextNode
+		return
+	}
+
+	r, _ := utf8.DecodeRuneInString(s.text[s.pos:])
+	switch {
+	case isCommentIndicator(r):
+		n.Kind = CommentNode
+		s.emit(CommentToken, len(s.text))
+		return
+	case !unicode.IsLetter(r):
+		n.Kind = TextNode
+		s.emit(TextToken, len(s.text))
+		return
+	}
+
+	n.Kind = PostingNode
+	account, next := accountEnd(s.line, s.pos)
+	s.emit(AccountToken, account)
+
+	end, count := spaceEnd(s.line, next)
+	if count < 2 {
+		if len(s.text)-end > 1 {
+			n.report(s.span(next, end), withHint(errors.New("not enough spaces following account"), "separate the account from its amount with at least two spaces or a tab"))
+		}
+		s.rest()
+		return
+	}
+	s.emit(WhitespaceToken, end)
+	if s.pos == len(s.text) {
+		return
+	}
+
+	// Postings can assert the account's balance without an amount
+	if s.pos < len(s.text) && s.text[s.pos] == '=' {
+		s.scanAssertion(n)
+		return
+	}
+
+	s.emitValue(CommodityToken, commodityEnd(s.line, s.pos))
+	end, _ = spaceEnd(s.line, s.pos)
+	s.emit(WhitespaceToken, end)
+
+	amount, next := amountEnd(s.line, s.pos)
+	s.emitValue(AmountToken, amount)
+
+	if end, _ := spaceEnd(s.line, next); end < len(s.text) && s.text[end] == '=' {
+		kind := WhitespaceToken
+		if len(strings.TrimSpace(s.text[s.pos:end])) > 0 {
+			kind = TextToken
+		}
+		s.emit(kind, end)
+		s.scanAssertion(n)
+		return
+	}
+	s.rest()
+}
+
+// scanAssertion splits a balance assertion such as '= £100'
+func (s *lineScanner) scanAssertion(n *SyntaxNode) {
+	equals := s.pos
+	s.emit(EqualsToken, s.pos+1)
+	end, _ := spaceEnd(s.line, s.pos)
+	s.emit(WhitespaceToken, end)
+
+	assertion, next := fieldEnd(s.line, s.pos)
+	if assertion == s.pos {
+		n.report(s.span(equals, next), withHint(errors.New("balance assertion is missing an amount"), "assert the account's balance after the posting with '= £100'"))
+		s.rest()
+		return
+	}
+	s.emit(AssertionToken, assertion)
+	s.rest()
+}
+
+// ParseTree reads the transactions, periodic transactions and declarations of a syntax tree,
+// passing them to the parser's handlers as Parse would.
+func (p *Parser) ParseTree(tree *SyntaxTree) error {
+	p.journalFiles = append(p.journalFiles, tree.Path)
+	defer func() { p.journalFiles = p.journalFiles[:len(p.journalFiles)-1] }()
+
+	// Errors are located with a lexer which is given each line in turn
+	lexer := newLexer(nil, tree.Path, p.parseItem)
+	if p.collectErrors {
+		lexer.recover = p.recoverFrom
+	}
+	previous := p.lexer
+	p.lexer = &lexer
+	defer func() { p.lexer = previous }()
+
+	for _, n := range tree.Nodes {
+		for _, line := range append([]*SyntaxNode{n}, n.Children...) {
+			if lexer.skipping && line.Kind != TransactionNode && line.Kind != PeriodicTransactionNode {
+				continue
+			}
+			lexer.skipping = false
+
+			lexer.input = []byte(line.Text())
+			lexer.currentLine = line.Line
+			lexer.start, lexer.pos = 0, 0
+			if err := p.parseNode(line); err != nil {
+				err = lexer.locate(err)
+				if lexer.recover == nil {
+					return err
+				}
+				if err = lexer.recover(err); err != nil {
+					return err
+				}
+				lexer.skipping = true
+			}
+		}
+	}
+
+	if err := p.parseItem(eofItem, nil); err != nil {
+		return lexer.locate(err)
+	}
+	if len(p.diagnostics) > 0 {
+		return p.diagnostics
+	}
+	return nil
+}
+
+// parseNode passes the items on a node's first line to the parser, then reports any problem with the line
+func (p *Parser) parseNode(n *SyntaxNode) error {
+	item := func(t itemType, token Token, content string) error {
+		p.lexer.start = token.Span.Start - n.Span.Start
+		p.lexer.pos = token.Span.End - n.Span.Start
+		return p.parseItem(t, []byte(content))
+	}
+	comment := func(token Token) string {
+		return strings.TrimLeft(token.Text[1:], " \t")
+	}
+
+	if n.Kind == BlankNode {
+		return p.parseItem(emptyLineItem, nil)
+	}
+
+	var err error
+	for i, t := range n.Tokens {
+		switch t.Kind {
+		case DateToken:
+			err = item(dateItem, t, t.Text)
+		case StateToken:
+			err = item(stateItem, t, t.Text)
+		case PayeeToken:
+			err = item(payeeItem, t, t.Text)
+		case NoteToken:
+			err = item(transactionHeaderCommentItem, t, t.Text)
+		case CommentToken:
+			switch {
+			case n.Kind == TransactionNode && len(comment(t)) > 0:
+				err = item(transactionHeaderCommentItem, t, comment(t))
+			case n.Kind == CommentNode && i > 0:
+				// Indented comments belong to the transaction or posting above
+				err = item(commentItem, t, comment(t))
+			}
+		case PeriodToken:
+			err = item(periodItem, t, t.Text)
+		case AccountToken:
+			err = item(accountItem, t, t.Text)
+		case CommodityToken:
+			content := t.Text
+			if i+1 < len(n.Tokens) && n.Tokens[i+1].Kind == WhitespaceToken {
+				content += " "
+			}
+			err = item(commodityItem, t, content)
+		case AmountToken:
+			if len(t.Text) == 0 && i > 0 {
+				// Everything after the account was taken as the commodity (as in £x)
+				commodity := n.Tokens[i-1]
+				if commodity.Kind == WhitespaceToken && i > 1 {
+					commodity = n.Tokens[i-2]
+				}
+				p.lexer.start = commodity.Span.Start - n.Span.Start
+				p.lexer.pos = commodity.Span.End - n.Span.Start
+				return errMissingAmount()
+			}
+			err = item(amountItem, t, t.Text)
+		case AssertionToken:
+			err = item(assertionItem, t, t.Text)
+		case DirectiveToken:
+			var argument *Token
+			if i+2 < len(n.Tokens) && n.Tokens[i+2].Kind == ArgumentToken {
+				argument = &n.Tokens[i+2]
+			}
+			switch {
+			case n.problem != nil:
+			case t.Text == "include" && argument != nil:
+				err = item(includeItem, *argument, argument.Text)
+			case argument != nil:
+				whole := Token{Span: Span{t.Span.Start, argument.Span.End}}
+				err = item(directiveItem, whole, t.Text+" "+argument.Text)
+			default:
+				err = item(directiveItem, t, t.Text)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.problem != nil {
+		p.lexer.start = n.problemSpan.Start - n.Span.Start
+		p.lexer.pos = n.problemSpan.End - n.Span.Start
+		return n.problem
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+const syntaxJournal = "; My journal\r\n" +
+	"account Assets:Current  ; main\n" +
+	"commodity £1,000.00\n" +
+	"\n" +
+	"2020-10-01 * Pay ;monthly\n" +
+	"    Income:Job\t£-2000.00\n" +
+	"\tAssets:Current    = £2000.00\n" +
+	"      ; a posting comment\n" +
+	"; about the shop\n" +
+	"2020/10/03 ! Shop  extra note\n" +
+	"  ; transaction note\n" +
+	"    Expenses:Food    £ 10   ; inline\n" +
+	"   \n" +
+	"    Expenses:Fun Stuff    £5  ignored\n" +
+	"    Assets:Current  £-10=£1990\n" +
+	"    Assets:Other  \n" +
+	"\n" +
+	"~ monthly  ; expected\n" +
+	"    Expenses:Food    £300\n" +
+	"    Assets:Current\n" +
+	"2020.10.04 Next\n" +
+	"    Expenses:Food    £1\n" +
+	"    Assets:Current"
+
+// Errors skip the lines up to the next transaction header, so each error here is followed by one
+const syntaxJournalWithErrors = journalWithErrors +
+	"\ninclude\n" +
+	"2020-10-06 Shop\n    Expenses:Food  £1\n    Assets:Current  =\n" +
+	"~monthly\n    Expenses:Food  £1\n    Assets:Current\n" +
+	"2020-10-07 Shop\n    Expenses:Food  £1\n    Assets:Current\n\nfoo bar\n" +
+	"2020-10-08 Shop\n    Expenses:Food  £1\n    Assets:Current\n! what\n" +
+	"2020-10-09 Shop\n    Expenses:Food  £1\n    Assets:Current\n"
+
+// describeParse describes everything a parser passes to its handlers
+func describeParse(parse func(p *Parser) error) (string, error) {
+	var b strings.Builder
+	p := NewParser(func(t *journal.Transaction, path string) error {
+		fmt.Fprintf(&b, "%s:%d\n%s", path, t.Line, t.String())
+		for _, posting := range t.Postings {
+			fmt.Fprintf(&b, "%d %q %v\n", posting.Line, posting.Comments, posting.Amount)
+		}
+		fmt.Fprintf(&b, "%q %q\n", t.HeaderNote, t.Notes)
+		return nil
+	}, func(pt *journal.PeriodicTransaction, path string) error {
+		fmt.Fprintf(&b, "%s:%d ~ %v\n%s", path, pt.Transaction.Line, pt.Period, pt.Transaction.String())
+		return nil
+	})
+	p.HandleDeclarations(func(d journal.Declaration, path string) error {
+		fmt.Fprintf(&b, "%s:%d %d %s\n", path, d.Line, d.Type, d.Name)
+		return nil
+	})
+	err := parse(&p)
+	return b.String(), err
+}
+
+func TestSyntaxTreeIsLossless(t *testing.T) {
+	for _, src := range []string{syntaxJournal, syntaxJournalWithErrors, "", "\n\n", "2020-10-01 x\r\n"} {
+		tree := ParseSyntax([]byte(src), "test.journal")
+		if string(tree.Bytes()) != src {
+			t.Fatalf("tree does not give back its source:\n%q\n%q", src, tree.Bytes())
+		}
+
+		// Every byte belongs to exactly one token, in order
+		position := 0
+		var check func(n *SyntaxNode)
+		check = func(n *SyntaxNode) {
+			for _, token := range n.Tokens {
+				if token.Span.Start != position || src[token.Span.Start:token.Span.End] != token.Text {
+					t.Fatalf("token %q has the wrong span %v", token.Text, token.Span)
+				}
+				position = token.Span.End
+			}
+			for _, c := range n.Children {
+				check(c)
+			}
+		}
+		for _, n := range tree.Nodes {
+			check(n)
+		}
+		if position != len(src) {
+			t.Fatalf("tokens end at %d rather than %d", position, len(src))
+		}
+	}
+}
+
+func TestSyntaxTreeStructure(t *testing.T) {
+	tree := ParseSyntax([]byte(syntaxJournal), "test.journal")
+
+	kinds := make([]NodeKind, 0, len(tree.Nodes))
+	for _, n := range tree.Nodes {
+		kinds = append(kinds, n.Kind)
+	}
+	expected := []NodeKind{CommentNode, DirectiveNode, DirectiveNode, BlankNode, TransactionNode, TransactionNode, BlankNode, PeriodicTransactionNode, TransactionNode}
+	if fmt.Sprint(kinds) != fmt.Sprint(expected) {
+		t.Fatalf("expected nodes %v, got %v", expected, kinds)
+	}
+
+	pay := tree.Nodes[4]
+	if pay.Line != 5 || len(pay.Children) != 4 || pay.Children[3].Kind != CommentNode {
+		t.Fatalf("transaction should hold its postings and the comment after them: %+v", pay)
+	}
+	if string(pay.Bytes()) != syntaxJournal[pay.Span.Start:pay.Span.End] {
+		t.Fatalf("transaction's span does not match its bytes")
+	}
+
+	shop := tree.Nodes[5]
+	for kind, text := range map[TokenKind]string{DateToken: "2020/10/03", StateToken: "!", PayeeToken: "Shop", NoteToken: "extra note"} {
+		if token, found := shop.Token(kind); !found || token.Text != text {
+			t.Fatalf("expected token %d to be %q, got %q", kind, text, token.Text)
+		}
+	}
+
+	assertion := shop.Children[4]
+	for kind, text := range map[TokenKind]string{AccountToken: "Assets:Current", CommodityToken: "£", AmountToken: "-10", EqualsToken: "=", AssertionToken: "£1990"} {
+		if token, found := assertion.Token(kind); !found || token.Text != text {
+			t.Fatalf("expected token %d to be %q, got %q", kind, text, token.Text)
+		}
+	}
+}
+
+func TestParseTreeMatchesParse(t *testing.T) {
+	for _, src := range []string{syntaxJournal, formattedJournal, unformattedJournal} {
+		expected, expectedErr := describeParse(func(p *Parser) error {
+			return p.Parse(strings.NewReader(src), "test.journal")
+		})
+		actual, err := describeParse(func(p *Parser) error {
+			return p.ParseTree(ParseSyntax([]byte(src), "test.journal"))
+		})
+		if err != nil || expectedErr != nil {
+			t.Fatalf("parsing failed: %v, %v", expectedErr, err)
+		}
+		if actual != expected {
+			t.Fatalf("tree parsed differently:\n%s\nexpected:\n%s", actual, expected)
+		}
+	}
+}
+
+// describeErrors describes the errors a parser returns, including where they are
+func describeErrors(err error) string {
+	var collected Diagnostics
+	if !errors.As(err, &collected) {
+		return fmt.Sprint(err)
+	}
+
+	var b strings.Builder
+	for _, d := range collected {
+		fmt.Fprintf(&b, "%s:%d:%d-%d %s (%s)\n", d.Path, d.Line, d.Column, d.EndColumn, d.Message, d.Hint)
+	}
+	return b.String()
+}
+
+func TestParseTreeErrorsMatchParse(t *testing.T) {
+	expected, expectedErr := describeParse(func(p *Parser) error {
+		p.CollectErrors(0)
+		return p.Parse(strings.NewReader(syntaxJournalWithErrors), "test.journal")
+	})
+	actual, err := describeParse(func(p *Parser) error {
+		p.CollectErrors(0)
+		return p.ParseTree(ParseSyntax([]byte(syntaxJournalWithErrors), "test.journal"))
+	})
+	if actual != expected {
+		t.Fatalf("tree parsed differently:\n%s\nexpected:\n%s", actual, expected)
+	}
+	if len(err.(Diagnostics)) != 8 {
+		t.Fatalf("expected 8 errors, got:\n%s", describeErrors(err))
+	}
+	if describeErrors(err) != describeErrors(expectedErr) {
+		t.Fatalf("tree reported different errors:\n%s\nexpected:\n%s", describeErrors(err), describeErrors(expectedErr))
+	}
+}
+
+// parseTreeComparisons are journals (including ones the lexer and the syntax tree once read differently)
+// which Parse and ParseTree must read the same way. They also seed FuzzParseTreeMatchesParse.
+var parseTreeComparisons = []string{
+	syntaxJournal,
+	syntaxJournalWithErrors,
+	formattedJournal,
+	unformattedJournal,
+	"10.01\n  A  =A",
+	"0\n\xbd",
+	"  A0 ",
+	"account\tAssets  ; note\npayee  Shop\nfoo bar\tbaz\n",
+	"2020-10-01\tShop\n    Expenses:Food    £1x0\t; note\n",
+	"~ monthly\t\n    Expenses:Food  £x\n    Assets:Current  = £1 ;\n",
+}
+
+// compareParseTree fails the test if ParseTree reads src differently to Parse, collecting every error
+func compareParseTree(t *testing.T, src []byte) {
+	expected, expectedErr := describeParse(func(p *Parser) error {
+		p.CollectErrors(0)
+		return p.Parse(bytes.NewReader(src), "test.journal")
+	})
+	actual, err := describeParse(func(p *Parser) error {
+		p.CollectErrors(0)
+		return p.ParseTree(ParseSyntax(src, "test.journal"))
+	})
+	if actual != expected {
+		t.Fatalf("tree parsed %q differently:\n%s\nexpected:\n%s", src, actual, expected)
+	}
+	if describeErrors(err) != describeErrors(expectedErr) {
+		t.Fatalf("tree reported different errors for %q:\n%s\nexpected:\n%s", src, describeErrors(err), describeErrors(expectedErr))
+	}
+}
+
+func TestParseTreeMatchesParseOnComparisons(t *testing.T) {
+	for _, src := range parseTreeComparisons {
+		compareParseTree(t, []byte(src))
+	}
+}
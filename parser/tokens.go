@@ -0,0 +1,141 @@
+// The lexer and the syntax tree split lines into tokens with these functions so that they read journals the same way
+
+package parser
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rikchilvers/gledger/shared"
+)
+
+// lineKind is what a line of a journal is, judged by how it starts
+type lineKind int
+
+const (
+	blankLine lineKind = iota
+	commentLine
+	directiveLine
+	periodicHeaderLine
+	headerLine
+	postingLine // an indented line, which might also be a comment or be ignored
+	unexpectedLine
+)
+
+// classifyLine says what a line (without its line ending) is
+func classifyLine(line []byte) lineKind {
+	if len(line) == 0 {
+		return blankLine
+	}
+
+	first, width := utf8.DecodeRune(line)
+	switch {
+	case isCommentIndicator(first):
+		return commentLine
+	case unicode.IsLetter(first):
+		return directiveLine
+	case first == '~':
+		return periodicHeaderLine
+	case unicode.IsNumber(first):
+		return headerLine
+	case first == '\t' || (first == ' ' && width < len(line) && line[width] == ' '):
+		return postingLine
+	default:
+		return unexpectedLine
+	}
+}
+
+// The end functions find where tokens starting at from end.
+// As the bytes they look for are ASCII, most scan bytes rather than runes without splitting any.
+
+// spaceEnd finds the end of the whitespace from a position.
+// Also returns how many spaces there were, counting tabs as shared.TabWidth.
+func spaceEnd(line []byte, from int) (end, count int) {
+	end = from
+	for end < len(line) {
+		r, width := utf8.DecodeRune(line[end:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		switch r {
+		case '\t':
+			count += shared.TabWidth
+		case ' ':
+			count++
+		}
+		end += width
+	}
+	return end, count
+}
+
+// wordEnd finds the end of a word, such as a date or the name of a directive: a space or a tab
+func wordEnd(line []byte, from int) int {
+	if end := bytes.IndexAny(line[from:], " \t"); end >= 0 {
+		return from + end
+	}
+	return len(line)
+}
+
+// directiveNameEnd finds the end of a directive's name: whitespace or where a field would end
+func directiveNameEnd(line []byte) int {
+	end, _ := fieldEnd(line, 0)
+	if word := wordEnd(line, 0); word < end {
+		return word
+	}
+	return end
+}
+
+// fieldEnd finds where a field such as a payee, period or amount ends: at a comment indicator, a tab or two spaces.
+// Returns the end of the field's content (without a trailing space)
+// and where lexing carries on from (after the indicator, tab or first of the two spaces).
+func fieldEnd(line []byte, from int) (end, next int) {
+	for i := from; i < len(line); i++ {
+		switch b := line[i]; {
+		case isCommentIndicator(rune(b)) || b == '\t':
+			return from + len(trimOneSpaceEnd(line[from:i])), i + 1
+		case b == ' ' && i+1 < len(line) && line[i+1] == ' ':
+			return i, i + 1
+		}
+	}
+	return from + len(trimOneSpaceEnd(line[from:])), len(line)
+}
+
+// accountEnd finds where an account ends: at a tab or two spaces.
+// Returns the end of the account (without a trailing space) and where lexing carries on from (at the tab or first space).
+func accountEnd(line []byte, from int) (end, next int) {
+	next = from
+	for next < len(line) {
+		b := line[next]
+		if b == '\t' || (b == ' ' && next+1 < len(line) && line[next+1] == ' ') {
+			break
+		}
+		next++
+	}
+	return from + len(trimOneSpaceEnd(line[from:next])), next
+}
+
+// commodityEnd finds where a commodity ends: at a digit, a sign or whitespace
+func commodityEnd(line []byte, from int) int {
+	end := from
+	for end < len(line) {
+		b := line[end]
+		if isDigit(b) || b == '-' || b == '+' || b == ' ' || b == '\t' {
+			break
+		}
+		_, width := utf8.DecodeRune(line[end:])
+		end += width
+	}
+	return end
+}
+
+// amountEnd finds where an amount ends: where a field would or before the = of a balance assertion.
+// Returns the end of the amount (without trailing whitespace) and where lexing carries on from.
+func amountEnd(line []byte, from int) (end, next int) {
+	end, next = fieldEnd(line, from)
+	if equals := bytes.IndexByte(line[from:end], '='); equals >= 0 {
+		next = from + equals
+		end = from + len(trimSpaceEnd(line[from:next]))
+	}
+	return end, next
+}
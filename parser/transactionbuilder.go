@@ -2,7 +2,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/rikchilvers/gledger/journal"
 )
@@ -21,6 +23,12 @@ type transactionBuilder struct {
 	periodicTransaction *journal.PeriodicTransaction // the periodic transaction we're building
 	currentPosting      *journal.Posting             // the current posting for the transaction
 	previousItemType    itemType                     // the previous item we were given
+	path                string                       // the file the transaction is in
+	line                int                          // the line the transaction starts on
+	source              []byte                       // the text of the line the transaction starts on (reused by each transaction)
+	year                int                          // the year of dates without one, set by year directives
+	defaultCommodity    string                       // the commodity of amounts without one, set by D directives
+	interned            map[string]string            // the account paths, payees and commodities seen so far
 }
 
 func newTransactionBuilder() transactionBuilder {
@@ -28,9 +36,22 @@ func newTransactionBuilder() transactionBuilder {
 		transactionType:  normalTransaction,
 		previousItemType: -1,
 		currentPosting:   nil,
+		interned:         make(map[string]string),
 	}
 }
 
+// intern returns content as a string, sharing one string between everything with the same content.
+// Journals repeat the same accounts, payees and commodities many times so this saves a string for each.
+func (tb *transactionBuilder) intern(content []byte) string {
+	// Looking up a converted []byte does not allocate
+	if s, found := tb.interned[string(content)]; found {
+		return s
+	}
+	s := string(content)
+	tb.interned[s] = s
+	return s
+}
+
 func (tb *transactionBuilder) beginTransaction(t transactionType) {
 	tb.transactionType = t
 	switch t {
@@ -47,7 +68,12 @@ func (tb *transactionBuilder) beginTransaction(t transactionType) {
 	tb.currentPosting.Transaction = tb.transaction
 }
 
-func (tb *transactionBuilder) build(t itemType, content []rune) error {
+func (tb *transactionBuilder) build(t itemType, content []byte) error {
+	if (tb.transactionType == normalTransaction && tb.transaction == nil) ||
+		(tb.transactionType == periodicTransaction && tb.periodicTransaction == nil) {
+		return withHint(fmt.Errorf("%s is not part of a transaction", t.description()), "postings and their comments must follow a transaction header without blank lines in between")
+	}
+
 	switch tb.transactionType {
 	case normalTransaction:
 		if err := tb.buildNormalTransaction(tb.transaction, t, content); err != nil {
@@ -63,26 +89,26 @@ func (tb *transactionBuilder) build(t itemType, content []rune) error {
 	return nil
 }
 
-func (tb *transactionBuilder) buildNormalTransaction(t *journal.Transaction, item itemType, content []rune) error {
+func (tb *transactionBuilder) buildNormalTransaction(t *journal.Transaction, item itemType, content []byte) error {
 	switch item {
 	case transactionHeaderCommentItem:
-		tb.transaction.HeaderNote = string(content)
+		t.HeaderNote = string(content)
 	case commentItem:
 		// check if we've got a posting to attach the comment to
 		if len(tb.currentPosting.AccountPath) != 0 {
 			tb.currentPosting.AddComment(string(content))
 		} else {
-			tb.transaction.AddNote(string(content))
+			t.AddNote(string(content))
 		}
 	case dateItem:
-		date, err := parseDate(content)
+		date, err := parseDateInYear(content, tb.year)
 		if err != nil {
-			return err
+			return withHint(fmt.Errorf("invalid date: %s", string(content)), "dates look like 2020-10-31, 2020/10/31 or 2020.10.31 (or 10/31 after a 'year 2020' directive)")
 		}
 		t.Date = date
 	case stateItem:
 		if tb.previousItemType != dateItem {
-			return fmt.Errorf("expected state but got %s", item)
+			return tb.unexpected(item)
 		}
 
 		switch content[0] {
@@ -95,18 +121,19 @@ func (tb *transactionBuilder) buildNormalTransaction(t *journal.Transaction, ite
 		}
 	case payeeItem:
 		if tb.previousItemType != dateItem && tb.previousItemType != stateItem {
-			return fmt.Errorf("expected payee but got %s", item)
+			return tb.unexpected(item)
 		}
 
-		t.Payee = string(content)
+		t.Payee = tb.intern(content)
 	case accountItem:
 		if tb.previousItemType != commentItem &&
 			tb.previousItemType != transactionHeaderCommentItem &&
 			tb.previousItemType != payeeItem &&
 			tb.previousItemType != amountItem &&
+			tb.previousItemType != assertionItem &&
 			tb.previousItemType != accountItem &&
 			tb.previousItemType != periodItem {
-			return fmt.Errorf("expected account but got %s", item)
+			return tb.unexpected(item)
 		}
 
 		// Accounts start a posting, so check if we need to start a new one
@@ -116,42 +143,73 @@ func (tb *transactionBuilder) buildNormalTransaction(t *journal.Transaction, ite
 		}
 
 		tb.currentPosting.Transaction = t
-		tb.currentPosting.AccountPath = string(content)
+		tb.currentPosting.AccountPath = tb.intern(content)
 	case commodityItem:
 		if tb.previousItemType != accountItem {
-			return fmt.Errorf("expected currency but got %s", item)
+			return tb.unexpected(item)
+		}
+
+		commodity := tb.intern(content)
+		if len(strings.TrimSpace(commodity)) == 0 && len(tb.defaultCommodity) > 0 {
+			commodity = tb.defaultCommodity
 		}
 
 		if tb.currentPosting.Amount == nil {
-			tb.currentPosting.Amount = journal.NewAmount(string(content), 0)
+			tb.currentPosting.Amount = journal.NewAmount(commodity, 0)
 		} else {
-			tb.currentPosting.Amount.Commodity = string(content)
+			tb.currentPosting.Amount.Commodity = commodity
 		}
 	case amountItem:
 		if tb.previousItemType != commodityItem && tb.previousItemType != payeeItem {
-			return fmt.Errorf("expected amount but got %s", item)
+			return tb.unexpected(item)
 		}
 
 		if tb.currentPosting.Amount == nil {
 			tb.currentPosting.Amount = journal.NewAmount("", 0)
 		}
 
+		if len(content) == 0 {
+			return errMissingAmount()
+		}
 		amount, err := parseAmount(content)
 		if err != nil {
-			return fmt.Errorf("error parsing amount: %w", err)
+			return withHint(fmt.Errorf("invalid amount: %s", string(content)), "amounts are numbers such as 10, -10.50 or +1000")
 		}
 		tb.currentPosting.Amount.Quantity = amount
+	case assertionItem:
+		if tb.previousItemType != amountItem && tb.previousItemType != accountItem {
+			return tb.unexpected(item)
+		}
+
+		assertion, err := ParseCommodityAmount(string(content))
+		if err != nil {
+			return withHint(fmt.Errorf("invalid balance assertion: %s", string(content)), "assert the account's balance after the posting with '= £100'")
+		}
+		if len(assertion.Commodity) == 0 {
+			assertion.Commodity = tb.defaultCommodity
+		}
+		tb.currentPosting.Assertion = &assertion
 	}
 
 	return nil
 }
 
-func (tb *transactionBuilder) buildPeriodicTransaction(t *journal.PeriodicTransaction, i itemType, content []rune) error {
+// errMissingAmount is the error for a posting with a commodity but no amount
+func errMissingAmount() error {
+	return withHint(errors.New("missing amount"), "amounts are numbers such as 10, -10.50 or +1000 and can follow a commodity such as £")
+}
+
+// unexpected describes an item which cannot follow the previous item
+func (tb *transactionBuilder) unexpected(item itemType) error {
+	return fmt.Errorf("%s cannot follow %s", item.description(), tb.previousItemType.description())
+}
+
+func (tb *transactionBuilder) buildPeriodicTransaction(t *journal.PeriodicTransaction, i itemType, content []byte) error {
 	switch i {
 	case periodItem:
 		period, err := parsePeriod(content)
 		if err != nil {
-			return err
+			return withHint(err, "periods are a month (2020-06) or an interval such as monthly or 'every 2 weeks from 2020-01-01'")
 		}
 		t.Period = period
 	default:
@@ -162,6 +220,13 @@ func (tb *transactionBuilder) buildPeriodicTransaction(t *journal.PeriodicTransa
 	return nil
 }
 
+// abandon drops the transaction being built
+func (tb *transactionBuilder) abandon() {
+	tb.transaction = nil
+	tb.periodicTransaction = nil
+	tb.currentPosting = nil
+}
+
 func (tb *transactionBuilder) endTransaction(p Parser) error {
 	switch tb.transactionType {
 	case normalTransaction:
@@ -212,12 +277,14 @@ func (tb *transactionBuilder) endNormalTransaction(t *journal.Transaction, p Par
 		}
 	}
 
-	// Before we close a budget transaction, we need to add the 'To Be Budgeted' account
+	// Before we close a budget transaction, we need to add the 'To Be Budgeted' account.
+	// Budget transactions with an elided posting move money between envelopes instead
+	// so the elided posting balances them without touching what is to be budgeted.
 	// TODO get rid of this and use root budget account instead
 	if tb.transactionType == periodicTransaction && tb.periodicTransaction.Period.Interval == journal.PNone {
 		hasBudgetSource := false
 		for _, p := range t.Postings {
-			if p.AccountPath == journal.BudgetRootID {
+			if p.AccountPath == journal.BudgetRootID || p.Amount == nil {
 				hasBudgetSource = true
 				break
 			}
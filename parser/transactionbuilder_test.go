@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/rikchilvers/gledger/journal"
@@ -13,34 +14,34 @@ func TestDateItemParsing(t *testing.T) {
 
 	// Correctly formed dates
 
-	err := builder.build(dateItem, []rune("2020/10/11"))
+	err := builder.build(dateItem, []byte("2020/10/11"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formatted date")
 	}
 
-	err = builder.build(dateItem, []rune("2020-10-11"))
+	err = builder.build(dateItem, []byte("2020-10-11"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formatted date")
 	}
 
-	err = builder.build(dateItem, []rune("2020.10.11"))
+	err = builder.build(dateItem, []byte("2020.10.11"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formatted date")
 	}
 
 	// Malformed dates
 
-	err = builder.build(dateItem, []rune("20201011"))
+	err = builder.build(dateItem, []byte("20201011"))
 	if err == nil {
 		t.Fatalf("parser does not fail for malformed dates")
 	}
 
-	err = builder.build(dateItem, []rune("2020-10-89"))
+	err = builder.build(dateItem, []byte("2020-10-89"))
 	if err == nil {
 		t.Fatalf("parser does not fail for malformed dates")
 	}
 
-	err = builder.build(dateItem, []rune("2020.10"))
+	err = builder.build(dateItem, []byte("2020.10"))
 	if err == nil {
 		t.Fatalf("parser does not fail for malformed dates")
 	}
@@ -54,7 +55,7 @@ func TestAmountItemParsing(t *testing.T) {
 	// Correctly formed amounts
 
 	builder.previousItemType = commodityItem
-	err := builder.build(amountItem, []rune("42.81"))
+	err := builder.build(amountItem, []byte("42.81"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formed amount: %s", err)
 	}
@@ -63,7 +64,7 @@ func TestAmountItemParsing(t *testing.T) {
 	}
 
 	builder.previousItemType = commodityItem
-	err = builder.build(amountItem, []rune("+42.81"))
+	err = builder.build(amountItem, []byte("+42.81"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formed amount: %s", err)
 	}
@@ -72,7 +73,7 @@ func TestAmountItemParsing(t *testing.T) {
 	}
 
 	builder.previousItemType = commodityItem
-	err = builder.build(amountItem, []rune("-42"))
+	err = builder.build(amountItem, []byte("-42"))
 	if err != nil {
 		t.Fatalf("parser returns error for correctly formed amount: %s", err)
 	}
@@ -85,14 +86,82 @@ func TestAmountItemParsing(t *testing.T) {
 	// Malformed amounts
 
 	builder.previousItemType = commodityItem
-	err = builder.build(amountItem, []rune("g81"))
+	err = builder.build(amountItem, []byte("g81"))
 	if err == nil {
 		t.Fatalf("parser returns no error for incorrectly formed amount: %s", err)
 	}
 
 	builder.previousItemType = commodityItem
-	err = builder.build(amountItem, []rune("8g1"))
+	err = builder.build(amountItem, []byte("8g1"))
 	if err == nil {
 		t.Fatalf("parser returns no error for incorrectly formed amount: %s", err)
 	}
 }
+
+func TestBudgetTransactionSources(t *testing.T) {
+	const input = `~ 2020-06
+    Groceries    £50.00
+
+~ 2020-06
+    ; move £50.00 from Dining Out to Groceries
+    Groceries    £50.00
+    Dining Out
+`
+
+	var transactions []*journal.PeriodicTransaction
+	p := NewParser(nil, func(pt *journal.PeriodicTransaction, _ string) error {
+		transactions = append(transactions, pt)
+		return nil
+	})
+	if err := p.Parse(strings.NewReader(input), "test.journal"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 budget transactions, got %d", len(transactions))
+	}
+
+	// Allocations are balanced against what is to be budgeted
+	allocation := transactions[0].Transaction.Postings
+	if len(allocation) != 2 || allocation[1].AccountPath != journal.BudgetRootID || allocation[1].Amount.Quantity != -5000 {
+		t.Fatalf("allocation was not balanced against the budget root")
+	}
+
+	// Moves are balanced by the elided envelope
+	if notes := transactions[1].Transaction.Notes; len(notes) != 1 {
+		t.Fatalf("expected the move to have a note, got %v", notes)
+	}
+	move := transactions[1].Transaction.Postings
+	if len(move) != 2 {
+		t.Fatalf("expected the move to have 2 postings, got %d", len(move))
+	}
+	if move[1].AccountPath != "Dining Out" || move[1].Amount.Quantity != -5000 {
+		t.Fatalf("move was not balanced by the elided envelope")
+	}
+}
+
+// Budget transactions written before moves existed keep their meaning:
+// the elided envelope balances the others and nothing is taken from what is to be budgeted
+func TestBudgetTransactionWithElidedEnvelope(t *testing.T) {
+	const input = `~ 2020-06
+    Groceries    £300
+    Dining Out    £100
+    Spare
+`
+
+	var transactions []*journal.PeriodicTransaction
+	p := NewParser(nil, func(pt *journal.PeriodicTransaction, _ string) error {
+		transactions = append(transactions, pt)
+		return nil
+	})
+	if err := p.Parse(strings.NewReader(input), "test.journal"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	postings := transactions[0].Transaction.Postings
+	if len(postings) != 3 {
+		t.Fatalf("expected 3 postings and none to the budget root, got %d", len(postings))
+	}
+	if postings[2].AccountPath != "Spare" || postings[2].Amount.Quantity != -40000 {
+		t.Fatalf("the elided envelope did not balance the others")
+	}
+}
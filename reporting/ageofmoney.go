@@ -0,0 +1,73 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// AgeOfMoneyReport shows how the age of money changed from month to month
+type AgeOfMoneyReport struct {
+	History []AgeOfMoneyResult `json:"history"`
+}
+
+// AgeOfMoneyResult is the age of money at the end of a month
+type AgeOfMoneyResult struct {
+	Month string  `json:"month"` // formatted as YYYY-MM
+	Days  float64 `json:"days"`
+}
+
+// NewAgeOfMoneyReport takes the age of money at the last outflow of each month.
+// Only outflows on or after start and before end are included (unless they are zero).
+func NewAgeOfMoneyReport(points []journal.AgeOfMoneyPoint, start, end time.Time) AgeOfMoneyReport {
+	r := AgeOfMoneyReport{
+		History: make([]AgeOfMoneyResult, 0),
+	}
+
+	for _, point := range points {
+		if (!start.IsZero() && point.Date.Before(start)) || (!end.IsZero() && !point.Date.Before(end)) {
+			continue
+		}
+
+		month := point.Date.Format(monthLayout)
+		if last := len(r.History) - 1; last >= 0 && r.History[last].Month == month {
+			r.History[last].Days = point.Days
+			continue
+		}
+		r.History = append(r.History, AgeOfMoneyResult{Month: month, Days: point.Days})
+	}
+
+	return r
+}
+
+// WriteText prints the age of money for each month
+func (r AgeOfMoneyReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if len(r.History) == 0 {
+		b.WriteString("No money has been spent.\n")
+		return writeString(w, &b)
+	}
+
+	fmt.Fprintf(&b, "%-10s | %s\n", "Month", "Age of money")
+	b.WriteString(strings.Repeat("-", 27))
+	b.WriteString("\n")
+	for _, month := range r.History {
+		fmt.Fprintf(&b, "%-10s | %7.f days\n", month.Month, month.Days)
+	}
+
+	return writeString(w, &b)
+}
+
+// Table lists the age of money for each month
+func (r AgeOfMoneyReport) Table() [][]string {
+	rows := [][]string{{"month", "days"}}
+	for _, month := range r.History {
+		rows = append(rows, []string{month.Month, strconv.FormatFloat(month.Days, 'f', 0, 64)})
+	}
+	return rows
+}
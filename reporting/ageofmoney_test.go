@@ -0,0 +1,29 @@
+package reporting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestAgeOfMoneyReportTakesEndOfMonth(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2020, month, d, 0, 0, 0, 0, time.UTC) }
+	points := []journal.AgeOfMoneyPoint{
+		{Date: day(time.September, 30), Days: 5},
+		{Date: day(time.October, 2), Days: 10},
+		{Date: day(time.October, 20), Days: 12},
+		{Date: day(time.November, 3), Days: 15},
+	}
+
+	r := NewAgeOfMoneyReport(points, day(time.October, 1), time.Time{})
+	expected := []AgeOfMoneyResult{{Month: "2020-10", Days: 12}, {Month: "2020-11", Days: 15}}
+	if len(r.History) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, r.History)
+	}
+	for i := range expected {
+		if r.History[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, r.History)
+		}
+	}
+}
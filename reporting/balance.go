@@ -0,0 +1,66 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// BalanceReport shows accounts and their balances
+type BalanceReport struct {
+	Accounts []AccountResult `json:"accounts"`
+	Total    AmountResult    `json:"total"`
+
+	root                       journal.Account
+	flattenTree                bool
+	shouldCollapseOnlyChildren bool
+}
+
+// NewBalanceReport creates a BalanceReport for the descendants of root
+func NewBalanceReport(root journal.Account, flattenTree, shouldCollapseOnlyChildren bool) BalanceReport {
+	accounts := NewAccountResult(root)
+	return BalanceReport{
+		Accounts:                   accounts.Children,
+		Total:                      accounts.Amount,
+		root:                       root,
+		flattenTree:                flattenTree,
+		shouldCollapseOnlyChildren: shouldCollapseOnlyChildren,
+	}
+}
+
+// WriteText prints the root account's descendents and its total
+func (r BalanceReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	prepender := func(a journal.Account) string {
+		return fmt.Sprintf("%20s  ", a.Amount.DisplayableQuantity(true))
+	}
+
+	if r.flattenTree {
+		b.WriteString(FlattenedTree(r.root, prepender))
+	} else {
+		b.WriteString(Tree(r.root, prepender, r.shouldCollapseOnlyChildren))
+	}
+	b.WriteString("\n")
+
+	// 20x '-' because that is how wide we format the amount to be
+	b.WriteString("--------------------\n")
+
+	// Print the root account's value
+	fmt.Fprintf(&b, "%20s\n", r.root.Amount.DisplayableQuantity(false))
+
+	return writeString(w, &b)
+}
+
+// Table lists every account with its total
+func (r BalanceReport) Table() [][]string {
+	rows := [][]string{{"account", "commodity", "amount"}}
+	for _, a := range r.Accounts {
+		a.walk(func(a AccountResult) {
+			rows = append(rows, append([]string{a.Path}, amountCells(a.Amount)...))
+		})
+	}
+	return rows
+}
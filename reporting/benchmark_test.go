@@ -0,0 +1,77 @@
+package reporting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// generateJournal creates a journal of n transactions, each moving money from a current account
+// to one of a few hundred expense accounts up to four levels deep
+func generateJournal(n int) (journal.Journal, []*journal.Transaction) {
+	accounts := make([]string, 0, 400)
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 8; j++ {
+			for k := 0; k < 5; k++ {
+				accounts = append(accounts, fmt.Sprintf("Expenses:Category %d:Group %d:Item %d", i, j, k))
+			}
+		}
+	}
+
+	j := journal.NewJournal()
+	transactions := make([]*journal.Transaction, 0, n)
+	date := time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		t := journal.NewTransaction()
+		t.Date = date.AddDate(0, 0, i/3)
+		t.Payee = fmt.Sprintf("Payee %d", i%50)
+		for _, p := range []struct {
+			account  string
+			quantity int64
+		}{{accounts[i%len(accounts)], int64(i%5000 + 1)}, {"Assets:Current", -int64(i%5000 + 1)}} {
+			posting := journal.NewPosting()
+			posting.Transaction = &t
+			posting.AccountPath = p.account
+			posting.Amount = journal.NewAmount("£", p.quantity)
+			t.AddPosting(posting)
+			if err := j.AddPosting(posting); err != nil {
+				panic(err)
+			}
+		}
+		j.AddTransaction(&t, "benchmark.journal")
+		transactions = append(transactions, &t)
+	}
+	return j, transactions
+}
+
+func BenchmarkTree(b *testing.B) {
+	j, _ := generateJournal(10000)
+	prepender := func(a journal.Account) string { return a.Amount.DisplayableQuantity(true) + "  " }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Tree(*j.Root, prepender, true)
+	}
+}
+
+func BenchmarkBalanceReport(b *testing.B) {
+	j, _ := generateJournal(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := NewBalanceReport(*j.Root, false, true).WriteText(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPrintReport(b *testing.B) {
+	_, transactions := generateJournal(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := NewPrintReport(transactions).WriteText(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/shared"
+)
+
+const monthLayout string = "2006-01"
+
+// BudgetReport shows the envelopes of each month in the budget
+type BudgetReport struct {
+	Months []BudgetMonthResult `json:"months"`
+}
+
+// BudgetMonthResult is the JSON representation of a journal.BudgetMonth
+type BudgetMonthResult struct {
+	Month              string                 `json:"month"`               // formatted as YYYY-MM
+	NotBudgeted        AmountResult           `json:"not_budgeted"`        // left unbudgeted in the previous month
+	Overspending       AmountResult           `json:"overspending"`        // overspent from cash in the previous month
+	CreditOverspending AmountResult           `json:"credit_overspending"` // overspent on credit in the previous month
+	Income             AmountResult           `json:"income"`
+	Budgeted           AmountResult           `json:"budgeted"`
+	Future             AmountResult           `json:"future"` // this month's funds budgeted in future months
+	ToBeBudgeted       AmountResult           `json:"to_be_budgeted"`
+	Underfunded        AmountResult           `json:"underfunded"` // still needed this month to meet the goals
+	Categories         []BudgetCategoryResult `json:"categories"`
+
+	previousMonth string
+}
+
+// BudgetCategoryResult is the JSON representation of an envelope and its descendants
+type BudgetCategoryResult struct {
+	Name      string                 `json:"name"`
+	Path      string                 `json:"path"`
+	Carried   AmountResult           `json:"carried"` // available at the end of the previous month
+	Budgeted  AmountResult           `json:"budgeted"`
+	Activity  AmountResult           `json:"activity"`
+	Available AmountResult           `json:"available"`
+	Goal      *BudgetGoalResult      `json:"goal,omitempty"`
+	Children  []BudgetCategoryResult `json:"children"`
+}
+
+// BudgetGoalResult is the JSON representation of a journal.GoalProgress
+type BudgetGoalResult struct {
+	Type     string       `json:"type"` // balance, target or monthly
+	Target   AmountResult `json:"target"`
+	By       string       `json:"by,omitempty"` // formatted as YYYY-MM
+	Funded   AmountResult `json:"funded"`
+	Needed   AmountResult `json:"needed"`   // still to budget this month
+	Progress float64      `json:"progress"` // between 0 and 1
+}
+
+// NewBudgetGoalResult creates a BudgetGoalResult
+func NewBudgetGoalResult(gp journal.GoalProgress) *BudgetGoalResult {
+	r := &BudgetGoalResult{
+		Target:   NewAmountResult(gp.Goal.Target),
+		Funded:   NewAmountResult(gp.Funded),
+		Needed:   NewAmountResult(gp.Needed),
+		Progress: gp.Progress(),
+	}
+
+	switch gp.Goal.Type {
+	case journal.BalanceGoal:
+		r.Type = "balance"
+	case journal.TargetDateGoal:
+		r.Type = "target"
+		r.By = gp.Goal.By.Format(monthLayout)
+	case journal.MonthlyGoal:
+		r.Type = "monthly"
+	}
+
+	return r
+}
+
+// NewBudgetReport creates a BudgetReport with the budget's months in order.
+// Only months on or after start and before end are included (unless they are zero).
+func NewBudgetReport(b journal.Budget, start, end time.Time) (BudgetReport, error) {
+	r := BudgetReport{
+		Months: make([]BudgetMonthResult, 0, len(b.Months)),
+	}
+
+	for _, month := range b.SortedMonths() {
+		if !start.IsZero() && month.Before(journal.NormaliseToMonth(start)) {
+			continue
+		}
+		if !end.IsZero() && !month.Before(end) {
+			continue
+		}
+
+		bm := b.Months[month]
+		categories, err := newBudgetCategoryResults(bm.EnvelopeRoot, bm.ExpenseRoot, bm.CarriedRoot, bm.Goals)
+		if err != nil {
+			return r, err
+		}
+
+		income := bm.Income.Amount
+		if income.Commodity == "" {
+			income.Commodity = b.Commodity
+		}
+
+		r.Months = append(r.Months, BudgetMonthResult{
+			Month:              month.Format(monthLayout),
+			NotBudgeted:        NewAmountResult(bm.NotBudgeted),
+			Overspending:       NewAmountResult(bm.Overspending),
+			CreditOverspending: NewAmountResult(bm.CreditOverspending),
+			Income:             NewAmountResult(income),
+			Budgeted:           NewAmountResult(bm.Budgeted),
+			Future:             NewAmountResult(bm.Future),
+			ToBeBudgeted:       NewAmountResult(bm.ToBeBudgeted),
+			Underfunded:        NewAmountResult(bm.Underfunded),
+			Categories:         categories,
+			previousMonth:      month.AddDate(0, -1, 0).Format(monthLayout),
+		})
+	}
+
+	return r, nil
+}
+
+// newBudgetCategoryResults pairs the envelope's children (and their descendants) with the matching expense and carried accounts
+// and the progress towards their goals
+func newBudgetCategoryResults(envelope, expense, carried *journal.Account, goals map[string]journal.GoalProgress) ([]BudgetCategoryResult, error) {
+	results := make([]BudgetCategoryResult, 0, len(envelope.Children))
+	for _, cn := range envelope.SortedChildNames() {
+		envelopeAccount := envelope.Children[cn]
+
+		expenseAccount, found := expense.Children[cn]
+		if !found {
+			return nil, fmt.Errorf("didn't find expense account %s", cn)
+		}
+
+		carriedAccount, found := carried.Children[cn]
+		if !found {
+			return nil, fmt.Errorf("didn't find carried account %s", cn)
+		}
+
+		available := envelopeAccount.Amount
+		if err := available.Add(expenseAccount.Amount); err != nil {
+			return nil, err
+		}
+		if err := available.Add(carriedAccount.Amount); err != nil {
+			return nil, err
+		}
+
+		children, err := newBudgetCategoryResults(envelopeAccount, expenseAccount, carriedAccount, goals)
+		if err != nil {
+			return nil, err
+		}
+
+		var goal *BudgetGoalResult
+		if gp, found := goals[strings.Join(envelopeAccount.PathComponents, ":")]; found {
+			goal = NewBudgetGoalResult(gp)
+		}
+
+		results = append(results, BudgetCategoryResult{
+			Name:      cn,
+			Path:      envelopeAccount.Path,
+			Carried:   NewAmountResult(carriedAccount.Amount),
+			Budgeted:  NewAmountResult(envelopeAccount.Amount),
+			Activity:  NewAmountResult(expenseAccount.Amount),
+			Available: NewAmountResult(available),
+			Goal:      goal,
+			Children:  children,
+		})
+	}
+
+	return results, nil
+}
+
+// WriteText prints a summary and the envelopes of each month
+func (r BudgetReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for _, month := range r.Months {
+		b.WriteString(month.Month)
+		b.WriteString("\n\n")
+
+		// Print the funds, showing what is taken from them as negative
+		summary := func(name string, a AmountResult, negate bool) {
+			amount := a.amount()
+			if negate {
+				amount.Quantity = -amount.Quantity
+			}
+			fmt.Fprintf(&b, "%30s | %20s\n", name, amount.DisplayableQuantity(true))
+		}
+		summary("Not budgeted in "+month.previousMonth, month.NotBudgeted, false)
+		summary("Overspent in "+month.previousMonth, month.Overspending, true)
+		summary("Income for "+month.Month, month.Income, false)
+		summary("Budgeted in "+month.Month, month.Budgeted, true)
+		summary("Budgeted in the future", month.Future, true)
+		b.WriteString("-----------------------------------------------------\n")
+		summary("To be budgeted", month.ToBeBudgeted, false)
+		if month.CreditOverspending.Quantity != 0 {
+			summary("Overspent on credit in "+month.previousMonth, month.CreditOverspending, false)
+		}
+		if month.Underfunded.Quantity != 0 {
+			summary("Underfunded goals", month.Underfunded, false)
+		}
+
+		// Padding
+		b.WriteString("\n\n")
+
+		// Goals get their own columns if any envelope has one
+		goals := hasBudgetGoals(month.Categories)
+
+		fmt.Fprintf(&b, "%-30s | %-20s | %-20s | %-20s | %-20s |", "Category", "Carried", "Budgeted", "Activity", "Available")
+		width := 124
+		if goals {
+			fmt.Fprintf(&b, " %-20s | %-20s |", "Goal", "Needed")
+			width += 46
+		}
+		b.WriteString("\n")
+		b.WriteString(strings.Repeat("-", width))
+		b.WriteString("\n")
+
+		// Print the envelopes
+		writeBudgetCategories(&b, month.Categories, 0, goals)
+
+		b.WriteString("\n")
+	}
+
+	return writeString(w, &b)
+}
+
+func writeBudgetCategories(b *strings.Builder, categories []BudgetCategoryResult, level int, goals bool) {
+	for _, c := range categories {
+		name := fmt.Sprintf("%s%s", strings.Repeat(" ", level*shared.TabWidth), c.Name)
+		fmt.Fprintf(b, "%-30s | %20s | %20s | %20s | %20s |", name, c.Carried.Value, c.Budgeted.Value, c.Activity.Value, c.Available.amount().DisplayableQuantity(true))
+		if goals {
+			if c.Goal != nil {
+				fmt.Fprintf(b, " %-20s | %20s |", progressBar(c.Goal.Progress), c.Goal.Needed.Value)
+			} else {
+				fmt.Fprintf(b, " %-20s | %20s |", "", "")
+			}
+		}
+		b.WriteString("\n")
+		writeBudgetCategories(b, c.Children, level+1, goals)
+	}
+}
+
+// hasBudgetGoals reports whether any of the categories or their descendants has a goal
+func hasBudgetGoals(categories []BudgetCategoryResult) bool {
+	for _, c := range categories {
+		if c.Goal != nil || hasBudgetGoals(c.Children) {
+			return true
+		}
+	}
+	return false
+}
+
+// progressBar draws progress (between 0 and 1) as a bar followed by a percentage
+func progressBar(progress float64) string {
+	const width = 10
+	filled := int(progress * width)
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), progress*100)
+}
+
+// Table lists every category of every month
+func (r BudgetReport) Table() [][]string {
+	rows := [][]string{{"month", "category", "commodity", "carried", "budgeted", "activity", "available", "goal", "needed"}}
+	for _, month := range r.Months {
+		var walk func(categories []BudgetCategoryResult)
+		walk = func(categories []BudgetCategoryResult) {
+			for _, c := range categories {
+				goal, needed := "", ""
+				if c.Goal != nil {
+					goal, needed = c.Goal.Target.Value, c.Goal.Needed.Value
+				}
+				rows = append(rows, []string{month.Month, c.Path, c.Available.Commodity, c.Carried.Value, c.Budgeted.Value, c.Activity.Value, c.Available.Value, goal, needed})
+				walk(c.Children)
+			}
+		}
+		walk(month.Categories)
+	}
+	return rows
+}
+
+// BudgetTableReport compares each category's envelope across months
+type BudgetTableReport struct {
+	Months     []string              `json:"months"` // formatted as YYYY-MM
+	Categories []BudgetTableCategory `json:"categories"`
+}
+
+// BudgetTableCategory is a single category in a BudgetTableReport
+type BudgetTableCategory struct {
+	Path   string            `json:"path"`
+	Months []BudgetTableCell `json:"months"` // in the same order as BudgetTableReport.Months
+}
+
+// BudgetTableCell is a category's envelope in a single month
+type BudgetTableCell struct {
+	Budgeted  AmountResult `json:"budgeted"`
+	Activity  AmountResult `json:"activity"`
+	Available AmountResult `json:"available"`
+}
+
+// NewBudgetTableReport rearranges a BudgetReport by category
+func NewBudgetTableReport(r BudgetReport) BudgetTableReport {
+	t := BudgetTableReport{
+		Months: make([]string, 0, len(r.Months)),
+	}
+
+	cells := make(map[string][]BudgetTableCell)
+	for i, month := range r.Months {
+		t.Months = append(t.Months, month.Month)
+
+		var walk func(categories []BudgetCategoryResult)
+		walk = func(categories []BudgetCategoryResult) {
+			for _, c := range categories {
+				if _, found := cells[c.Path]; !found {
+					// Months without the category show zero
+					zero := NewAmountResult(journal.Amount{})
+					cells[c.Path] = make([]BudgetTableCell, len(r.Months))
+					for j := range cells[c.Path] {
+						cells[c.Path][j] = BudgetTableCell{Budgeted: zero, Activity: zero, Available: zero}
+					}
+				}
+				cells[c.Path][i] = BudgetTableCell{
+					Budgeted:  c.Budgeted,
+					Activity:  c.Activity,
+					Available: c.Available,
+				}
+				walk(c.Children)
+			}
+		}
+		walk(month.Categories)
+	}
+
+	paths := make([]string, 0, len(cells))
+	for path := range cells {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return lessAccountPath(paths[i], paths[j])
+	})
+
+	for _, path := range paths {
+		t.Categories = append(t.Categories, BudgetTableCategory{Path: path, Months: cells[path]})
+	}
+
+	return t
+}
+
+// lessAccountPath orders paths component by component so that accounts are followed by their descendants
+func lessAccountPath(a, b string) bool {
+	ac, bc := strings.Split(a, ":"), strings.Split(b, ":")
+	for i := 0; i < len(ac) && i < len(bc); i++ {
+		if ac[i] != bc[i] {
+			return ac[i] < bc[i]
+		}
+	}
+	return len(ac) < len(bc)
+}
+
+// WriteText prints a row per category with the budgeted, activity and available amounts for each month
+func (r BudgetTableReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-30s |", "Category")
+	for _, month := range r.Months {
+		fmt.Fprintf(&b, " %32s |", month)
+	}
+	fmt.Fprintf(&b, "\n%-30s |", "")
+	for range r.Months {
+		fmt.Fprintf(&b, " %10s %10s %10s |", "Budgeted", "Activity", "Available")
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat("-", 32+35*len(r.Months)))
+	b.WriteString("\n")
+
+	for _, c := range r.Categories {
+		components := strings.Split(c.Path, ":")
+		name := fmt.Sprintf("%s%s", strings.Repeat(" ", (len(components)-1)*shared.TabWidth), components[len(components)-1])
+		fmt.Fprintf(&b, "%-30s |", name)
+		for _, cell := range c.Months {
+			fmt.Fprintf(&b, " %10s %10s %10s |", cell.Budgeted.Value, cell.Activity.Value, cell.Available.Value)
+		}
+		b.WriteString("\n")
+	}
+
+	return writeString(w, &b)
+}
+
+// Table lists a row per category with columns for each month's amounts
+func (r BudgetTableReport) Table() [][]string {
+	header := []string{"category"}
+	for _, month := range r.Months {
+		header = append(header, month+" budgeted", month+" activity", month+" available")
+	}
+
+	rows := [][]string{header}
+	for _, c := range r.Categories {
+		row := []string{c.Path}
+		for _, cell := range c.Months {
+			row = append(row, cell.Budgeted.Value, cell.Activity.Value, cell.Available.Value)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,50 @@
+package reporting
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLessAccountPath(t *testing.T) {
+	paths := []string{"Fun:Hobbies", "Fun2", "Bills", "Fun"}
+	sort.Slice(paths, func(i, j int) bool {
+		return lessAccountPath(paths[i], paths[j])
+	})
+
+	expected := []string{"Bills", "Fun", "Fun:Hobbies", "Fun2"}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, paths)
+		}
+	}
+}
+
+func TestBudgetTableReport(t *testing.T) {
+	amount := func(value string) AmountResult { return AmountResult{Commodity: "£", Value: value} }
+	r := BudgetReport{Months: []BudgetMonthResult{
+		{Month: "2020-10", Categories: []BudgetCategoryResult{
+			{Name: "Clothing", Path: "Clothing", Budgeted: amount("50.00"), Activity: amount("-40.00"), Available: amount("10.00")},
+		}},
+		{Month: "2020-11", Categories: []BudgetCategoryResult{
+			{Name: "Groceries", Path: "Groceries", Budgeted: amount("45.00"), Activity: amount("-30.00"), Available: amount("15.00")},
+		}},
+	}}
+
+	table := NewBudgetTableReport(r).Table()
+	expected := [][]string{
+		{"category", "2020-10 budgeted", "2020-10 activity", "2020-10 available", "2020-11 budgeted", "2020-11 activity", "2020-11 available"},
+		{"Clothing", "50.00", "-40.00", "10.00", "0.00", "0.00", "0.00"},
+		{"Groceries", "0.00", "0.00", "0.00", "45.00", "-30.00", "15.00"},
+	}
+
+	if len(table) != len(expected) {
+		t.Fatalf("expected %d rows, got %d", len(expected), len(table))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if table[i][j] != expected[i][j] {
+				t.Fatalf("row %d: expected %v, got %v", i, expected[i], table[i])
+			}
+		}
+	}
+}
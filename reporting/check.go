@@ -0,0 +1,48 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rikchilvers/gledger/parser"
+)
+
+// CheckReport lists the problems found when checking a journal
+type CheckReport struct {
+	Diagnostics parser.Diagnostics `json:"diagnostics"`
+}
+
+// NewCheckReport creates a CheckReport
+func NewCheckReport(diagnostics parser.Diagnostics) CheckReport {
+	if diagnostics == nil {
+		diagnostics = make(parser.Diagnostics, 0)
+	}
+	return CheckReport{Diagnostics: diagnostics}
+}
+
+// WriteText prints each problem with the line it is on, or nothing if there are none
+func (r CheckReport) WriteText(w io.Writer) error {
+	if len(r.Diagnostics) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, r.Diagnostics.Error())
+	return err
+}
+
+// Table lists every problem
+func (r CheckReport) Table() [][]string {
+	rows := [][]string{{"path", "line", "column", "end_column", "severity", "message", "hint"}}
+	for _, d := range r.Diagnostics {
+		rows = append(rows, []string{
+			d.Path,
+			strconv.Itoa(d.Line),
+			strconv.Itoa(d.Column),
+			strconv.Itoa(d.EndColumn),
+			d.Severity.String(),
+			d.Message,
+			d.Hint,
+		})
+	}
+	return rows
+}
@@ -2,6 +2,7 @@ package reporting
 
 import (
 	"regexp"
+	"strings"
 	"unicode"
 
 	"github.com/rikchilvers/gledger/journal"
@@ -24,11 +25,28 @@ const (
 	NoteFilter
 )
 
+// AccountMatchType describes how an account filter compares itself to account paths
+type AccountMatchType int
+
+const (
+	RegexAccountMatch      AccountMatchType = iota // regex matching anywhere in the path
+	ExactAccountMatch                              // :Expenses:Fun$ matches only Expenses:Fun
+	SubtreeAccountMatch                            // :Expenses:Fun matches Expenses:Fun and its descendants
+	DescendantAccountMatch                         // :Expenses:Fun: matches only the descendants of Expenses:Fun
+)
+
 type Filter struct {
-	regex      *regexp.Regexp
-	FilterType FilterType
+	regex            *regexp.Regexp
+	FilterType       FilterType
+	AccountMatchType AccountMatchType
+	account          string // the account path for non-regex account matches
+	foldCase         bool
 }
 
+// NewFilter creates a Filter from a command line argument.
+// Arguments starting with '@' match payees and '=' match notes.
+// Arguments starting with ':' match account paths respecting the ':' boundaries between accounts.
+// Anything else is a regex matched against account paths.
 func NewFilter(arg string) (Filter, error) {
 	filter := Filter{}
 
@@ -39,6 +57,9 @@ func NewFilter(arg string) (Filter, error) {
 	case '=':
 		filter.FilterType = NoteFilter
 		arg = arg[1:]
+	case ':':
+		filter.FilterType = AccountNameFilter
+		return newAccountFilter(filter, arg[1:]), nil
 	default:
 		filter.FilterType = AccountNameFilter
 	}
@@ -56,6 +77,88 @@ func NewFilter(arg string) (Filter, error) {
 	return filter, nil
 }
 
+func newAccountFilter(filter Filter, account string) Filter {
+	switch {
+	case strings.HasSuffix(account, "$"):
+		filter.AccountMatchType = ExactAccountMatch
+		account = strings.TrimSuffix(account, "$")
+	case strings.HasSuffix(account, ":"):
+		filter.AccountMatchType = DescendantAccountMatch
+		account = strings.TrimSuffix(account, ":")
+	default:
+		filter.AccountMatchType = SubtreeAccountMatch
+	}
+
+	// Follow the regex filters in only being case sensitive when there is an uppercase letter
+	filter.foldCase = !ContainsUppercase(account)
+	if filter.foldCase {
+		account = strings.ToLower(account)
+	}
+	filter.account = account
+
+	return filter
+}
+
+// MatchesAccount checks the account path against the filter
+func (f Filter) MatchesAccount(path string) bool {
+	if f.AccountMatchType == RegexAccountMatch {
+		return f.regex.MatchString(path)
+	}
+	if f.foldCase {
+		path = strings.ToLower(path)
+	}
+	return MatchesAccountPath(f.account, path, f.AccountMatchType)
+}
+
+// MatchesAccountPath compares two account paths component by component
+// so that 'Expenses:Fun' is related to 'Expenses:Fun:Hobbies' but not 'Expenses:Funeral'
+func MatchesAccountPath(account, path string, matchType AccountMatchType) bool {
+	if path == account {
+		return matchType == ExactAccountMatch || matchType == SubtreeAccountMatch
+	}
+
+	isDescendant := len(path) > len(account) &&
+		path[len(account)] == ':' &&
+		strings.HasPrefix(path, account)
+
+	return isDescendant && (matchType == SubtreeAccountMatch || matchType == DescendantAccountMatch)
+}
+
+// MatchesCategory checks a category of the accounts in set, such as a budget envelope, against the filter.
+// Account paths match if they match an account in set with that category.
+// Regexes are matched against the category and its paths under the set's roots.
+func (f Filter) MatchesCategory(set journal.AccountSet, category string) bool {
+	if f.AccountMatchType == RegexAccountMatch {
+		if f.regex.MatchString(category) {
+			return true
+		}
+		for _, path := range set.RootPaths(category) {
+			if f.regex.MatchString(path) {
+				return true
+			}
+		}
+		return false
+	}
+	if f.foldCase {
+		set, category = set.FoldCase(), strings.ToLower(category)
+	}
+
+	// The accounts the filter can match are its account or one of its ancestors followed by the category
+	// (or the category itself, which is the whole path of accounts with nothing below the part the set matched)
+	matches := func(path string) bool {
+		return set.Matches(path) && strings.Join(set.CategoryOrPath(path), ":") == category && f.MatchesAccount(path)
+	}
+	if matches(category) {
+		return true
+	}
+	for i := 0; i <= len(f.account); i++ {
+		if (i == len(f.account) || f.account[i] == ':') && matches(f.account[:i]+":"+category) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f Filter) MatchesTransactionHow(t journal.Transaction) (matchesPayee, matchesTransactionNote bool, matchedPostings []*journal.Posting) {
 	mp := make(map[*journal.Posting]bool)
 	switch f.FilterType {
@@ -78,10 +181,9 @@ func (f Filter) MatchesTransactionHow(t journal.Transaction) (matchesPayee, matc
 				}
 			}
 			if f.FilterType == AccountNameFilter {
-				if f.regex.MatchString(p.AccountPath) {
+				if f.MatchesAccount(p.AccountPath) {
 					mp[p] = true
 				}
-				// TODO: match child accounts
 			}
 		}
 	}
@@ -114,17 +216,19 @@ func (f Filter) MatchesTransaction(t journal.Transaction) bool {
 		}
 	case AccountNameFilter:
 		for _, p := range t.Postings {
-			if f.regex.MatchString(p.AccountPath) {
+			if f.MatchesAccount(p.AccountPath) {
 				return true
 			}
 		}
-		// TODO: match child accounts
 	}
 
 	return false
 }
 
 func (f Filter) MatchesString(s string) bool {
+	if f.FilterType == AccountNameFilter {
+		return f.MatchesAccount(s)
+	}
 	return f.regex.MatchString(s)
 }
 
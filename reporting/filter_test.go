@@ -0,0 +1,101 @@
+package reporting
+
+import (
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestAccountFilterMatchTypes(t *testing.T) {
+	filter, err := NewFilter(":Expenses:Fun")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filter.AccountMatchType != SubtreeAccountMatch {
+		t.Fatalf("expected subtree match but got %d", filter.AccountMatchType)
+	}
+
+	filter, _ = NewFilter(":Expenses:Fun$")
+	if filter.AccountMatchType != ExactAccountMatch {
+		t.Fatalf("expected exact match but got %d", filter.AccountMatchType)
+	}
+
+	filter, _ = NewFilter(":Expenses:Fun:")
+	if filter.AccountMatchType != DescendantAccountMatch {
+		t.Fatalf("expected descendant match but got %d", filter.AccountMatchType)
+	}
+
+	filter, _ = NewFilter("Fun")
+	if filter.AccountMatchType != RegexAccountMatch {
+		t.Fatalf("expected regex match but got %d", filter.AccountMatchType)
+	}
+}
+
+func TestAccountFilterRespectsBoundaries(t *testing.T) {
+	tests := []struct {
+		filter   string
+		path     string
+		expected bool
+	}{
+		{":Expenses:Fun", "Expenses:Fun", true},
+		{":Expenses:Fun", "Expenses:Fun:Hobbies", true},
+		{":Expenses:Fun", "Expenses:Funeral", false},
+		{":Expenses:Fun", "Expenses", false},
+		{":Expenses:Fun$", "Expenses:Fun", true},
+		{":Expenses:Fun$", "Expenses:Fun:Hobbies", false},
+		{":Expenses:Fun:", "Expenses:Fun", false},
+		{":Expenses:Fun:", "Expenses:Fun:Hobbies", true},
+		{":Expenses:Fun:", "Expenses:Funeral:Flowers", false},
+		{":expenses:fun", "Expenses:Fun:Hobbies", true},
+		{":Expenses:fun", "Expenses:Fun", false},
+		{"Fun", "Expenses:Funeral", true},
+	}
+
+	for _, test := range tests {
+		filter, err := NewFilter(test.filter)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := filter.MatchesAccount(test.path); got != test.expected {
+			t.Fatalf("filter '%s' against '%s': expected %t, got %t", test.filter, test.path, test.expected, got)
+		}
+	}
+}
+
+func TestFilterMatchesEnvelopeCategories(t *testing.T) {
+	expenses, err := journal.NewAccountSet("Expenses", "/^Spending:/", "/^(Bills|Subscriptions)/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		filter   string
+		category string
+		expected bool
+	}{
+		{":Expenses:Food", "Food:Groceries", true},
+		{":Expenses:Food", "Fun", false},
+		{":expenses:food$", "Food", true},
+		{":Expenses:Food:", "Food", false},
+		{":Expenses", "Fun", true},
+		{":Spending:Food", "Food", true},
+		{":Spending:Food", "Fun", false},
+		{":Bills", "Bills", true},
+		{":Bills$", "Subscriptions", false},
+		{":Food", "Food", false},
+		{":Assets", "Food", false},
+		{"^expenses:food", "Food:Groceries", true},
+		{"groceries", "Food:Groceries", true},
+		{"^Spending:Food", "Food", false},
+	}
+
+	for _, test := range tests {
+		filter, err := NewFilter(test.filter)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := filter.MatchesCategory(expenses, test.category); got != test.expected {
+			t.Fatalf("filter '%s' against envelope '%s': expected %t, got %t", test.filter, test.category, test.expected, got)
+		}
+	}
+}
@@ -9,7 +9,7 @@ import (
 func TestFlattenedTree(t *testing.T) {
 	prepender := func(a journal.Account) string { return "" }
 	root, expected, _ := createRoot()
-	got := root.FlattenedTree(prepender)
+	got := FlattenedTree(*root, prepender)
 
 	if got != expected {
 		t.Fatalf("\nExpected:\n'%s'\nGot:\n'%s'", expected, got)
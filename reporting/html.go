@@ -0,0 +1,144 @@
+package reporting
+
+import (
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// HTMLReport is implemented by reports which can be rendered as a self-contained web page
+type HTMLReport interface {
+	WriteHTML(w io.Writer) error
+}
+
+const htmlStyle = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; text-align: left; }
+td.amount, th.amount { text-align: right; font-variant-numeric: tabular-nums; white-space: nowrap; }
+tr.total td { font-weight: bold; border-top: 2px solid #222; }
+.negative { color: #c0392b; }
+.tree details { margin-left: 1.5em; }
+.tree > details { margin-left: 0; }
+.tree summary, .tree .leaf { display: flex; justify-content: space-between; padding: 0.2em 0; border-bottom: 1px solid #eee; cursor: pointer; }
+.tree .leaf { margin-left: 1.5em; cursor: default; }
+.tree .name { flex: 1; }
+.tree .amount { font-variant-numeric: tabular-nums; white-space: nowrap; }
+.depth-1 td:first-child { padding-left: 1.8em; }
+.depth-2 td:first-child { padding-left: 3em; }
+.depth-3 td:first-child { padding-left: 4.2em; }
+`
+
+const htmlLayout = `{{define "page"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>{{style}}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{template "content" .Report}}
+</body>
+</html>
+{{end}}
+{{define "amount"}}<span class="amount{{if negative .}} negative{{end}}">{{display .}}</span>{{end}}
+`
+
+var htmlFuncs = template.FuncMap{
+	"style":    func() template.CSS { return template.CSS(htmlStyle) },
+	"negative": func(a AmountResult) bool { return a.Quantity < 0 },
+	"display":  func(a AmountResult) string { return a.amount().DisplayableQuantity(true) },
+	"depth":    func(path string) int { return strings.Count(path, ":") },
+	"negate": func(a AmountResult) AmountResult {
+		return NewAmountResult(journal.Amount{Commodity: a.Commodity, Quantity: -a.Quantity})
+	},
+}
+
+// newHTMLTemplate combines the page layout with a template defining the report's "content"
+func newHTMLTemplate(content string) *template.Template {
+	return template.Must(template.Must(template.New("page").Funcs(htmlFuncs).Parse(htmlLayout)).Parse(content))
+}
+
+func writeHTML(w io.Writer, t *template.Template, title string, report interface{}) error {
+	return t.ExecuteTemplate(w, "page", struct {
+		Title  string
+		Report interface{}
+	}{title, report})
+}
+
+var balanceHTML = newHTMLTemplate(`
+{{define "content"}}<div class="tree">
+{{range .Accounts}}{{template "account" .}}{{end}}
+</div>
+<table><tr class="total"><td>Total</td><td class="amount">{{template "amount" .Total}}</td></tr></table>
+{{end}}
+{{define "account"}}{{if .Children}}<details open>
+<summary><span class="name">{{.Name}}</span>{{template "amount" .Amount}}</summary>
+{{range .Children}}{{template "account" .}}{{end}}
+</details>
+{{else}}<div class="leaf"><span class="name">{{.Name}}</span>{{template "amount" .Amount}}</div>
+{{end}}{{end}}
+`)
+
+// WriteHTML renders the accounts as a collapsible tree
+func (r BalanceReport) WriteHTML(w io.Writer) error {
+	return writeHTML(w, balanceHTML, "Balance", r)
+}
+
+var registerHTML = newHTMLTemplate(`
+{{define "content"}}<table>
+<tr><th>Date</th><th>Payee</th><th>Account</th><th class="amount">Amount</th><th class="amount">Total</th></tr>
+{{range .Postings}}<tr><td>{{.Date}}</td><td>{{.Payee}}</td><td>{{.Account}}</td><td class="amount">{{template "amount" .Amount}}</td><td class="amount">{{template "amount" .Total}}</td></tr>
+{{end}}</table>
+{{end}}
+`)
+
+// WriteHTML renders the postings as a table
+func (r RegisterReport) WriteHTML(w io.Writer) error {
+	return writeHTML(w, registerHTML, "Register", r)
+}
+
+var budgetHTML = newHTMLTemplate(`
+{{define "content"}}{{range .Months}}<h2>{{.Month}}</h2>
+<table>
+<tr><td>Not budgeted last month</td><td class="amount">{{template "amount" .NotBudgeted}}</td></tr>
+<tr><td>Overspent last month</td><td class="amount">{{template "amount" (negate .Overspending)}}</td></tr>
+<tr><td>Income</td><td class="amount">{{template "amount" .Income}}</td></tr>
+<tr><td>Budgeted</td><td class="amount">{{template "amount" (negate .Budgeted)}}</td></tr>
+<tr><td>Budgeted in the future</td><td class="amount">{{template "amount" (negate .Future)}}</td></tr>
+<tr class="total"><td>To be budgeted</td><td class="amount">{{template "amount" .ToBeBudgeted}}</td></tr>
+{{if .CreditOverspending.Quantity}}<tr><td>Overspent on credit last month</td><td class="amount">{{template "amount" .CreditOverspending}}</td></tr>{{end}}
+{{if .Underfunded.Quantity}}<tr><td>Underfunded goals</td><td class="amount">{{template "amount" .Underfunded}}</td></tr>{{end}}
+</table>
+<table>
+<tr><th>Category</th><th class="amount">Carried</th><th class="amount">Budgeted</th><th class="amount">Activity</th><th class="amount">Available</th><th>Goal</th><th class="amount">Needed</th></tr>
+{{template "categories" .Categories}}</table>
+{{end}}{{end}}
+{{define "categories"}}{{range .}}<tr class="depth-{{depth .Path}}"><td>{{.Name}}</td><td class="amount">{{template "amount" .Carried}}</td><td class="amount">{{template "amount" .Budgeted}}</td><td class="amount">{{template "amount" .Activity}}</td><td class="amount">{{template "amount" .Available}}</td>{{with .Goal}}<td><progress value="{{.Progress}}" max="1"></progress></td><td class="amount">{{template "amount" .Needed}}</td>{{else}}<td></td><td></td>{{end}}</tr>
+{{template "categories" .Children}}{{end}}{{end}}
+`)
+
+// WriteHTML renders each month's envelopes as a table
+func (r BudgetReport) WriteHTML(w io.Writer) error {
+	return writeHTML(w, budgetHTML, "Budget", r)
+}
+
+var budgetPerformanceHTML = newHTMLTemplate(`
+{{define "content"}}{{range .Periods}}<h2>{{.Start}} to {{.LastDay}}</h2>
+<table>
+<tr><th>Account</th><th class="amount">Actual</th><th class="amount">Budget</th><th class="amount">% used</th></tr>
+{{template "performance" .Accounts}}</table>
+{{end}}{{end}}
+{{define "performance"}}{{range .}}<tr class="depth-{{depth .Path}}"><td>{{.Name}}</td><td class="amount">{{template "amount" .Actual}}</td><td class="amount">{{template "amount" .Budget}}</td><td class="amount">{{.PercentUsed}}</td></tr>
+{{template "performance" .Children}}{{end}}{{end}}
+`)
+
+// WriteHTML renders each period's accounts as a table
+func (r BudgetPerformanceReport) WriteHTML(w io.Writer) error {
+	return writeHTML(w, budgetPerformanceHTML, "Budget performance", r)
+}
@@ -0,0 +1,45 @@
+package reporting
+
+import (
+	"io"
+	"strings"
+)
+
+// AccountsReport lists account names
+type AccountsReport struct {
+	Accounts []string `json:"accounts"`
+}
+
+// WriteText prints an account per line
+func (r AccountsReport) WriteText(w io.Writer) error {
+	return writeLines(w, r.Accounts)
+}
+
+// Table lists an account per row
+func (r AccountsReport) Table() [][]string {
+	return listTable("account", r.Accounts)
+}
+
+// PayeesReport lists payees
+type PayeesReport struct {
+	Payees []string `json:"payees"`
+}
+
+// WriteText prints a payee per line
+func (r PayeesReport) WriteText(w io.Writer) error {
+	return writeLines(w, r.Payees)
+}
+
+// Table lists a payee per row
+func (r PayeesReport) Table() [][]string {
+	return listTable("payee", r.Payees)
+}
+
+func writeLines(w io.Writer, lines []string) error {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	return writeString(w, &b)
+}
@@ -0,0 +1,53 @@
+package reporting
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rikchilvers/gledger/shared"
+)
+
+// FormatForPath infers a Format from the extension of path.
+// Returns false if the extension is not recognised.
+func FormatForPath(path string) (Format, bool) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if len(ext) == 0 {
+		return TextFormat, false
+	}
+	format, err := ParseFormat(ext)
+	if err != nil {
+		return TextFormat, false
+	}
+	return format, true
+}
+
+// Output is the destination reports are written to
+type Output struct {
+	Format Format
+	path   string // stdout if empty
+	stdout io.Writer
+}
+
+// NewOutput creates an Output writing to the file at path (or stdout if path is empty)
+func NewOutput(path string, format Format) Output {
+	return Output{
+		Format: format,
+		path:   path,
+		stdout: os.Stdout,
+	}
+}
+
+// Write renders the report to the Output.
+// Files are written atomically: the report is rendered to a temporary file
+// which replaces the destination only once rendering has succeeded.
+func (o Output) Write(r Report) error {
+	if len(o.path) == 0 {
+		return Render(o.stdout, r, o.Format)
+	}
+
+	return shared.WriteFileAtomically(o.path, 0644, func(w io.Writer) error {
+		return Render(w, r, o.Format)
+	})
+}
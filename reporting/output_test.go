@@ -0,0 +1,63 @@
+package reporting
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatForPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected Format
+		ok       bool
+	}{
+		{"out.csv", CSVFormat, true},
+		{"out.TSV", TSVFormat, true},
+		{"reports/out.json", JSONFormat, true},
+		{"out.html", HTMLFormat, true},
+		{"out.txt", TextFormat, true},
+		{"out.xlsx", TextFormat, false},
+		{"out", TextFormat, false},
+	}
+
+	for _, test := range tests {
+		got, ok := FormatForPath(test.path)
+		if got != test.expected || ok != test.ok {
+			t.Fatalf("'%s': expected (%d, %t), got (%d, %t)", test.path, test.expected, test.ok, got, ok)
+		}
+	}
+}
+
+func TestOutputWritesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gledger")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "payees.csv")
+	if err := ioutil.WriteFile(path, []byte("old contents"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output := NewOutput(path, CSVFormat)
+	if err := output.Write(PayeesReport{Payees: []string{"A shop"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(contents) != "payee\nA shop\n" {
+		t.Fatalf("unexpected contents: '%s'", contents)
+	}
+
+	// The temporary file should not be left behind
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file in the directory, got %d", len(files))
+	}
+}
@@ -0,0 +1,138 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+	"github.com/rikchilvers/gledger/shared"
+)
+
+// BudgetPerformanceReport compares what was posted to accounts with what periodic transactions expected, period by period
+type BudgetPerformanceReport struct {
+	Periods []BudgetPerformancePeriod `json:"periods"`
+}
+
+// BudgetPerformancePeriod is a single period of a BudgetPerformanceReport
+type BudgetPerformancePeriod struct {
+	Start    string                    `json:"start"` // formatted as YYYY-MM-DD
+	End      string                    `json:"end"`   // the day after the period ends
+	Accounts []BudgetPerformanceResult `json:"accounts"`
+}
+
+// BudgetPerformanceResult is the JSON representation of a budgeted account and its descendants
+type BudgetPerformanceResult struct {
+	Name     string                    `json:"name"`
+	Path     string                    `json:"path"`
+	Actual   AmountResult              `json:"actual"`
+	Budget   AmountResult              `json:"budget"`
+	Percent  *float64                  `json:"percent"` // actual as a percentage of budget, null if nothing was budgeted
+	Children []BudgetPerformanceResult `json:"children"`
+}
+
+// NewBudgetPerformancePeriod pairs each account in the budget tree (and its descendants) with the same account in the actual tree
+func NewBudgetPerformancePeriod(start, end time.Time, actual, budget journal.Account) BudgetPerformancePeriod {
+	return BudgetPerformancePeriod{
+		Start:    start.Format(dateLayout),
+		End:      end.Format(dateLayout),
+		Accounts: newBudgetPerformanceResults(&actual, &budget),
+	}
+}
+
+func newBudgetPerformanceResults(actual, budget *journal.Account) []BudgetPerformanceResult {
+	results := make([]BudgetPerformanceResult, 0, len(budget.Children))
+	for _, name := range budget.SortedChildNames() {
+		budgetAccount := budget.Children[name]
+
+		// Accounts without postings in the period have nothing to show
+		var actualAccount *journal.Account
+		if actual != nil {
+			actualAccount = actual.Children[name]
+		}
+		actualAmount := journal.Amount{Commodity: budgetAccount.Amount.Commodity}
+		if actualAccount != nil {
+			actualAmount = actualAccount.Amount
+		}
+
+		var percent *float64
+		if budgetAccount.Amount.Quantity != 0 {
+			p := 100 * float64(actualAmount.Quantity) / float64(budgetAccount.Amount.Quantity)
+			percent = &p
+		}
+
+		results = append(results, BudgetPerformanceResult{
+			Name:     name,
+			Path:     budgetAccount.Path,
+			Actual:   NewAmountResult(actualAmount),
+			Budget:   NewAmountResult(budgetAccount.Amount),
+			Percent:  percent,
+			Children: newBudgetPerformanceResults(actualAccount, budgetAccount),
+		})
+	}
+	return results
+}
+
+// PercentUsed formats the percentage of the budget used, or "-" if nothing was budgeted
+func (r BudgetPerformanceResult) PercentUsed() string {
+	if r.Percent == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", *r.Percent)
+}
+
+// WriteText prints the actual and budgeted amounts of each account for every period
+func (r BudgetPerformanceReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for _, period := range r.Periods {
+		fmt.Fprintf(&b, "%s to %s\n\n", period.Start, period.LastDay())
+		fmt.Fprintf(&b, "%-30s | %-20s | %-20s | %-8s |\n", "Account", "Actual", "Budget", "% used")
+		b.WriteString(strings.Repeat("-", 89))
+		b.WriteString("\n")
+
+		var walk func(accounts []BudgetPerformanceResult, level int)
+		walk = func(accounts []BudgetPerformanceResult, level int) {
+			for _, a := range accounts {
+				name := fmt.Sprintf("%s%s", strings.Repeat(" ", level*shared.TabWidth), a.Name)
+				fmt.Fprintf(&b, "%-30s | %20s | %20s | %8s |\n", name, a.Actual.amount().DisplayableQuantity(true), a.Budget.amount().DisplayableQuantity(true), a.PercentUsed())
+				walk(a.Children, level+1)
+			}
+		}
+		walk(period.Accounts, 0)
+
+		b.WriteString("\n")
+	}
+
+	return writeString(w, &b)
+}
+
+// LastDay returns the last day in the period
+func (p BudgetPerformancePeriod) LastDay() string {
+	end, err := time.Parse(dateLayout, p.End)
+	if err != nil {
+		return p.End
+	}
+	return end.AddDate(0, 0, -1).Format(dateLayout)
+}
+
+// Table lists every budgeted account of every period
+func (r BudgetPerformanceReport) Table() [][]string {
+	rows := [][]string{{"start", "end", "account", "commodity", "actual", "budget", "percent"}}
+	for _, period := range r.Periods {
+		var walk func(accounts []BudgetPerformanceResult)
+		walk = func(accounts []BudgetPerformanceResult) {
+			for _, a := range accounts {
+				percent := ""
+				if a.Percent != nil {
+					percent = fmt.Sprintf("%.2f", *a.Percent)
+				}
+				rows = append(rows, []string{period.Start, period.End, a.Path, a.Budget.Commodity, a.Actual.Value, a.Budget.Value, percent})
+				walk(a.Children)
+			}
+		}
+		walk(period.Accounts)
+	}
+	return rows
+}
@@ -0,0 +1,49 @@
+package reporting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestBudgetPerformancePeriod(t *testing.T) {
+	actual := journal.NewAccount(journal.RootID)
+	budget := journal.NewAccount(journal.RootID)
+
+	add := func(root *journal.Account, path []string, quantity int64) {
+		root.FindOrCreateAccount(path).WalkAncestors(func(a *journal.Account) error {
+			a.Amount.Commodity = "£"
+			a.Amount.Quantity += quantity
+			return nil
+		})
+	}
+	add(budget, []string{"Expenses", "Groceries"}, 20000)
+	add(budget, []string{"Expenses", "Fun"}, 0)
+	add(actual, []string{"Expenses", "Groceries"}, 5000)
+	// Unbudgeted accounts are left out
+	add(actual, []string{"Expenses", "Clothing"}, 4000)
+
+	october := time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)
+	period := NewBudgetPerformancePeriod(october, october.AddDate(0, 1, 0), *actual, *budget)
+
+	if period.LastDay() != "2020-10-31" {
+		t.Fatalf("expected the period to end on 2020-10-31, got %s", period.LastDay())
+	}
+
+	if len(period.Accounts) != 1 || len(period.Accounts[0].Children) != 2 {
+		t.Fatalf("expected only the budgeted accounts")
+	}
+	expenses := period.Accounts[0]
+	if expenses.Actual.Value != "90.00" || expenses.PercentUsed() != "45%" {
+		t.Fatalf("expenses: expected 90.00 and 45%%, got %s and %s", expenses.Actual.Value, expenses.PercentUsed())
+	}
+
+	fun, groceries := expenses.Children[0], expenses.Children[1]
+	if fun.Percent != nil || fun.PercentUsed() != "-" {
+		t.Fatalf("fun has no budget so should have no percentage")
+	}
+	if groceries.Actual.Value != "50.00" || groceries.PercentUsed() != "25%" {
+		t.Fatalf("groceries: expected 50.00 and 25%%, got %s and %s", groceries.Actual.Value, groceries.PercentUsed())
+	}
+}
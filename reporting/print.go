@@ -0,0 +1,49 @@
+package reporting
+
+import (
+	"io"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// PrintReport shows transaction entries
+type PrintReport struct {
+	Transactions []TransactionResult `json:"transactions"`
+
+	transactions []*journal.Transaction
+}
+
+// NewPrintReport creates a PrintReport from transactions sorted by date
+func NewPrintReport(transactions []*journal.Transaction) PrintReport {
+	r := PrintReport{
+		Transactions: make([]TransactionResult, 0, len(transactions)),
+		transactions: transactions,
+	}
+	for _, t := range transactions {
+		r.Transactions = append(r.Transactions, NewTransactionResult(*t))
+	}
+	return r
+}
+
+// WriteText prints the transactions as journal entries
+func (r PrintReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, t := range r.transactions {
+		b.WriteString(t.String())
+		b.WriteString("\n")
+	}
+	return writeString(w, &b)
+}
+
+// Table lists every posting alongside its transaction's details
+func (r PrintReport) Table() [][]string {
+	rows := [][]string{{"date", "state", "payee", "note", "account", "commodity", "amount", "file"}}
+	for _, t := range r.Transactions {
+		for _, p := range t.Postings {
+			row := append([]string{t.Date, t.State, t.Payee, t.Note, p.Account}, amountCells(p.Amount)...)
+			rows = append(rows, append(row, t.File))
+		}
+	}
+	return rows
+}
@@ -0,0 +1,94 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+// RegisterReport shows postings and a running total
+type RegisterReport struct {
+	Postings []RegisterRow `json:"postings"`
+}
+
+// RegisterRow is a single posting in a RegisterReport
+type RegisterRow struct {
+	Date    string       `json:"date"` // formatted as YYYY-MM-DD
+	Payee   string       `json:"payee"`
+	Account string       `json:"account"`
+	Amount  AmountResult `json:"amount"`
+	Total   AmountResult `json:"total"` // the running total including this posting
+	File    string       `json:"file"`  // the journal file the posting was found in
+
+	transaction *journal.Transaction
+}
+
+// NewRegisterReport creates a RegisterReport from postings sorted by date.
+// Account names are clipped to depth (if it is greater than zero).
+func NewRegisterReport(postings []*journal.Posting, depth int) RegisterReport {
+	r := RegisterReport{
+		Postings: make([]RegisterRow, 0, len(postings)),
+	}
+
+	var total journal.Amount
+	for _, p := range postings {
+		if total.Commodity == "" {
+			total.Commodity = p.Amount.Commodity
+		}
+		total.Add(*p.Amount)
+
+		r.Postings = append(r.Postings, RegisterRow{
+			Date:        p.Transaction.Date.Format(dateLayout),
+			Payee:       p.Transaction.Payee,
+			Account:     journal.ClipPath(p.AccountPath, depth),
+			Amount:      NewAmountResult(*p.Amount),
+			Total:       NewAmountResult(total),
+			File:        p.Transaction.Path,
+			transaction: p.Transaction,
+		})
+	}
+
+	return r
+}
+
+// WriteText prints a line per posting
+func (r RegisterReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	var previous *journal.Transaction
+
+	for _, row := range r.Postings {
+		// Only show the date and payee for the first posting of each transaction
+		date, payee := "", ""
+		if row.transaction != previous {
+			date = row.Date
+			payee = row.Payee
+			previous = row.transaction
+		}
+
+		fmt.Fprintf(&b, "%-10s %-24s %-30s %14s %14s\n",
+			date, truncate(payee, 24), truncate(row.Account, 30),
+			row.Amount.amount().DisplayableQuantity(true), row.Total.amount().DisplayableQuantity(true))
+	}
+
+	return writeString(w, &b)
+}
+
+// Table lists every posting with the running total
+func (r RegisterReport) Table() [][]string {
+	rows := [][]string{{"date", "payee", "account", "commodity", "amount", "total", "file"}}
+	for _, row := range r.Postings {
+		rows = append(rows, []string{row.Date, row.Payee, row.Account, row.Amount.Commodity, row.Amount.Value, row.Total.Value, row.File})
+	}
+	return rows
+}
+
+// truncate shortens s to width runes, marking that it was shortened
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-2]) + ".."
+}
@@ -0,0 +1,83 @@
+package reporting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format describes how a Report is rendered
+type Format int
+
+// Formats a Report can be rendered in
+const (
+	TextFormat Format = iota
+	JSONFormat
+	CSVFormat
+	TSVFormat
+	HTMLFormat
+)
+
+// ParseFormat converts the name of a format (as passed to --output-format) to a Format
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "text", "txt":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	case "csv":
+		return CSVFormat, nil
+	case "tsv":
+		return TSVFormat, nil
+	case "html":
+		return HTMLFormat, nil
+	default:
+		return TextFormat, fmt.Errorf("unknown output format: %s", name)
+	}
+}
+
+// Report is the structured result of a command.
+// Its exported fields are its JSON representation.
+type Report interface {
+	// WriteText writes the report as it is shown in the terminal
+	WriteText(w io.Writer) error
+	// Table returns the report as rows of cells (beginning with a header row)
+	Table() [][]string
+}
+
+// Render writes the report to w in the given format
+func Render(w io.Writer, r Report, f Format) error {
+	switch f {
+	case TextFormat:
+		return r.WriteText(w)
+	case JSONFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		// Payees such as M&S and files such as <stdin> are written as they are
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(r)
+	case CSVFormat, TSVFormat:
+		writer := csv.NewWriter(w)
+		if f == TSVFormat {
+			writer.Comma = '\t'
+		}
+		return writer.WriteAll(r.Table())
+	case HTMLFormat:
+		hr, ok := r.(HTMLReport)
+		if !ok {
+			return errors.New("html output is not supported for this report")
+		}
+		return hr.WriteHTML(w)
+	default:
+		return fmt.Errorf("unhandled output format: %d", f)
+	}
+}
+
+// writeString writes the contents of the builder to w
+func writeString(w io.Writer, b *strings.Builder) error {
+	_, err := io.WriteString(w, b.String())
+	return err
+}
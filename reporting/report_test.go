@@ -0,0 +1,72 @@
+package reporting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rikchilvers/gledger/journal"
+)
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{"": TextFormat, "text": TextFormat, "JSON": JSONFormat, "csv": CSVFormat, "tsv": TSVFormat} {
+		got, err := ParseFormat(name)
+		if err != nil {
+			t.Fatalf("unexpected error for '%s': %s", name, err)
+		}
+		if got != expected {
+			t.Fatalf("format '%s': expected %d, got %d", name, expected, got)
+		}
+	}
+
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func TestRenderBalance(t *testing.T) {
+	root := journal.NewAccount(journal.RootID)
+	current := root.FindOrCreateAccount([]string{"Assets", "Current"})
+	current.WalkAncestors(func(a *journal.Account) error {
+		a.Amount = journal.Amount{Commodity: "£", Quantity: 4281}
+		return nil
+	})
+	report := NewBalanceReport(*root, false, false)
+
+	var b strings.Builder
+	if err := Render(&b, report, CSVFormat); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "account,commodity,amount\nAssets,£,42.81\nAssets:Current,£,42.81\n"
+	if b.String() != expected {
+		t.Fatalf("\nExpected:\n%s\nGot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := Render(&b, report, JSONFormat); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(b.String(), `"path": "Assets:Current"`) || !strings.Contains(b.String(), `"quantity": 4281`) {
+		t.Fatalf("json is missing the account:\n%s", b.String())
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	root := journal.NewAccount(journal.RootID)
+	income := root.FindOrCreateAccount([]string{"Income", "Work"})
+	income.WalkAncestors(func(a *journal.Account) error {
+		a.Amount = journal.Amount{Commodity: "£", Quantity: -100}
+		return nil
+	})
+
+	var b strings.Builder
+	if err := Render(&b, NewBalanceReport(*root, false, false), HTMLFormat); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(b.String(), `<span class="amount negative">£-1.00</span>`) {
+		t.Fatalf("html does not mark negative amounts:\n%s", b.String())
+	}
+
+	if err := Render(&b, AccountsReport{}, HTMLFormat); err == nil {
+		t.Fatalf("expected an error for a report without html support")
+	}
+}
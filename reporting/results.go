@@ -0,0 +1,139 @@
+package reporting
+
+import "github.com/rikchilvers/gledger/journal"
+
+const dateLayout string = "2006-01-02"
+
+// AmountResult is the JSON representation of a journal.Amount
+type AmountResult struct {
+	Commodity string `json:"commodity"`
+	Quantity  int64  `json:"quantity"` // in hundredths of the commodity (i.e. pence for £)
+	Value     string `json:"value"`    // the quantity as a decimal string (e.g. "42.81")
+}
+
+// NewAmountResult converts a journal.Amount
+func NewAmountResult(a journal.Amount) AmountResult {
+	return AmountResult{
+		Commodity: a.Commodity,
+		Quantity:  a.Quantity,
+		Value:     a.DisplayableQuantity(false),
+	}
+}
+
+func (a AmountResult) amount() journal.Amount {
+	return journal.Amount{Commodity: a.Commodity, Quantity: a.Quantity}
+}
+
+// PostingResult is the JSON representation of a journal.Posting
+type PostingResult struct {
+	Account   string        `json:"account"`
+	Amount    AmountResult  `json:"amount"`
+	Assertion *AmountResult `json:"assertion,omitempty"` // the balance asserted after the posting
+	Comments  []string      `json:"comments"`
+}
+
+// NewPostingResult converts a journal.Posting
+func NewPostingResult(p journal.Posting) PostingResult {
+	result := PostingResult{
+		Account:  p.AccountPath,
+		Comments: p.Comments,
+	}
+	if p.Amount != nil {
+		result.Amount = NewAmountResult(*p.Amount)
+	}
+	if p.Assertion != nil {
+		assertion := NewAmountResult(*p.Assertion)
+		result.Assertion = &assertion
+	}
+	if result.Comments == nil {
+		result.Comments = []string{}
+	}
+	return result
+}
+
+// TransactionResult is the JSON representation of a journal.Transaction
+type TransactionResult struct {
+	Date     string          `json:"date"`  // formatted as YYYY-MM-DD
+	State    string          `json:"state"` // one of "", "uncleared" or "cleared"
+	Payee    string          `json:"payee"`
+	Note     string          `json:"note"`  // the note in the transaction header
+	Notes    []string        `json:"notes"` // the notes beneath the transaction header
+	Postings []PostingResult `json:"postings"`
+	File     string          `json:"file"` // the journal file the transaction was found in
+	Line     int             `json:"line"` // the line of the file the transaction starts on
+}
+
+// NewTransactionResult converts a journal.Transaction
+func NewTransactionResult(t journal.Transaction) TransactionResult {
+	result := TransactionResult{
+		Date:     t.Date.Format(dateLayout),
+		State:    stateName(t.State),
+		Payee:    t.Payee,
+		Note:     t.HeaderNote,
+		Notes:    t.Notes,
+		Postings: make([]PostingResult, 0, len(t.Postings)),
+		File:     t.Path,
+		Line:     t.Line,
+	}
+	if result.Notes == nil {
+		result.Notes = []string{}
+	}
+	for _, p := range t.Postings {
+		result.Postings = append(result.Postings, NewPostingResult(*p))
+	}
+	return result
+}
+
+func stateName(state journal.TransactionState) string {
+	switch state {
+	case journal.UnclearedState:
+		return "uncleared"
+	case journal.ClearedState:
+		return "cleared"
+	default:
+		return ""
+	}
+}
+
+// AccountResult is the JSON representation of a journal.Account and its descendants
+type AccountResult struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path"`
+	Amount   AmountResult    `json:"amount"` // includes the amounts of all descendants
+	Children []AccountResult `json:"children"`
+}
+
+// NewAccountResult converts a journal.Account and its descendants
+func NewAccountResult(a journal.Account) AccountResult {
+	result := AccountResult{
+		Name:     a.Name,
+		Path:     a.Path,
+		Amount:   NewAmountResult(a.Amount),
+		Children: make([]AccountResult, 0, len(a.Children)),
+	}
+	for _, name := range a.SortedChildNames() {
+		result.Children = append(result.Children, NewAccountResult(*a.Children[name]))
+	}
+	return result
+}
+
+// walk calls action on this account and its descendants, depth first
+func (a AccountResult) walk(action func(a AccountResult)) {
+	action(a)
+	for _, c := range a.Children {
+		c.walk(action)
+	}
+}
+
+func listTable(header string, values []string) [][]string {
+	rows := make([][]string, 0, len(values)+1)
+	rows = append(rows, []string{header})
+	for _, v := range values {
+		rows = append(rows, []string{v})
+	}
+	return rows
+}
+
+func amountCells(a AmountResult) []string {
+	return []string{a.Commodity, a.Value}
+}
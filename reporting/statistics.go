@@ -0,0 +1,104 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// StatisticsReport shows statistics about the journal
+type StatisticsReport struct {
+	Files              []string `json:"files"`
+	FirstTransaction   string   `json:"first_transaction"` // formatted as YYYY-MM-DD
+	LastTransaction    string   `json:"last_transaction"`  // formatted as YYYY-MM-DD
+	Days               int      `json:"days"`
+	Transactions       int      `json:"transactions"`
+	TransactionsPerDay float64  `json:"transactions_per_day"`
+	UniqueAccounts     int      `json:"unique_accounts"`
+	UniquePayees       int      `json:"unique_payees"`
+	AgeOfMoney         float64  `json:"age_of_money"` // in days
+
+	firstTransactionDate time.Time
+	lastTransactionDate  time.Time
+}
+
+// NewStatisticsReport creates a StatisticsReport, calculating the time period covered by the transactions
+func NewStatisticsReport(files []string, first, last time.Time, transactions, accounts, payees int, ageOfMoney float64) StatisticsReport {
+	days := math.Round(last.Sub(first).Hours() / 24)
+	perDay := 0.0
+	if days > 0 {
+		perDay = float64(transactions) / days
+	}
+
+	return StatisticsReport{
+		Files:                files,
+		FirstTransaction:     first.Format(dateLayout),
+		LastTransaction:      last.Format(dateLayout),
+		Days:                 int(days),
+		Transactions:         transactions,
+		TransactionsPerDay:   perDay,
+		UniqueAccounts:       accounts,
+		UniquePayees:         payees,
+		AgeOfMoney:           ageOfMoney,
+		firstTransactionDate: first,
+		lastTransactionDate:  last,
+	}
+}
+
+// WriteText prints the statistics
+func (r StatisticsReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if r.Transactions == 0 {
+		b.WriteString("No transactions matched arguments.\n")
+		return writeString(w, &b)
+	}
+
+	// Report the files
+	fmt.Fprintf(&b, "Transactions found in %d files:\n", len(r.Files))
+	for _, p := range r.Files {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+
+	// Report start and end dates
+	fmt.Fprintf(&b, "First transaction:\t%s (%s)\n", r.FirstTransaction, humanize.Time(r.firstTransactionDate))
+	fmt.Fprintf(&b, "Last transaction:\t%s (%s)\n", r.LastTransaction, humanize.Time(r.lastTransactionDate))
+
+	// Report duration
+	fmt.Fprintf(&b, "Time period:\t\t%d days\n", r.Days)
+
+	// Report transaction count
+	fmt.Fprintf(&b, "Transactions:\t\t%d (%.1f per day)\n", r.Transactions, r.TransactionsPerDay)
+
+	// Report number of unique accounts
+	fmt.Fprintf(&b, "Unique accounts:\t%d\n", r.UniqueAccounts)
+
+	// Report number of unique payees
+	fmt.Fprintf(&b, "Unique payees:\t\t%d\n", r.UniquePayees)
+
+	// Report age of money
+	fmt.Fprintf(&b, "Age of money:\t\t%.f days\n", r.AgeOfMoney)
+
+	return writeString(w, &b)
+}
+
+// Table lists each statistic as a row
+func (r StatisticsReport) Table() [][]string {
+	return [][]string{
+		{"statistic", "value"},
+		{"files", strings.Join(r.Files, ";")},
+		{"first_transaction", r.FirstTransaction},
+		{"last_transaction", r.LastTransaction},
+		{"days", strconv.Itoa(r.Days)},
+		{"transactions", strconv.Itoa(r.Transactions)},
+		{"transactions_per_day", strconv.FormatFloat(r.TransactionsPerDay, 'f', 2, 64)},
+		{"unique_accounts", strconv.Itoa(r.UniqueAccounts)},
+		{"unique_payees", strconv.Itoa(r.UniquePayees)},
+		{"age_of_money", strconv.FormatFloat(r.AgeOfMoney, 'f', 0, 64)},
+	}
+}
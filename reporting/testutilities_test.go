@@ -0,0 +1,41 @@
+package reporting
+
+import "github.com/rikchilvers/gledger/journal"
+
+func createRoot() (*journal.Account, string, string) {
+	/* Flattened */
+	f := `A0:A1a
+A0:A1b:A2:A3
+E0:E1a:E2a:E3a
+E0:E1a:E2a:E3b
+E0:E1b:E2b
+I0:I1`
+
+	/* Tree */
+	t := `A0
+	A1a
+	A1b:A2:A3
+E0
+	E1a:E2a
+		E3a
+		E3b
+	E1b:E2b
+I0:I1`
+
+	root := journal.NewAccount(journal.RootID)
+
+	components := [][]string{
+		{"A0", "A1a"},
+		{"A0", "A1b", "A2", "A3"},
+		{"E0", "E1a", "E2a", "E3a"},
+		{"E0", "E1a", "E2a", "E3b"},
+		{"E0", "E1b", "E2b"},
+		{"I0", "I1"},
+	}
+
+	for _, c := range components {
+		root.FindOrCreateAccount(c)
+	}
+
+	return root, f, t
+}
@@ -1,7 +1,7 @@
 package reporting
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/rikchilvers/gledger/journal"
 )
@@ -12,17 +12,29 @@ type treeContext struct {
 	isOnlyChild                bool
 	collapsedAccounts          string
 	collapsedAccountsDepth     int
-	tree                       string
+	tree                       *strings.Builder // shared by every copy of the context so lines are only written once
 	shouldCollapseOnlyChildren bool
 }
 
 func newTreeContext(p func(a journal.Account) string, shouldCollapseOnlyChildren bool) treeContext {
 	return treeContext{
 		prepender:                  p,
+		tree:                       &strings.Builder{},
 		shouldCollapseOnlyChildren: shouldCollapseOnlyChildren,
 	}
 }
 
+// writeLine adds a line to the tree
+func (c treeContext) writeLine(a journal.Account, depth int, name string) {
+	// If this is the first account, don't add a newline
+	if c.tree.Len() > 0 {
+		c.tree.WriteByte('\n')
+	}
+	c.tree.WriteString(c.prepender(a))
+	c.tree.WriteString(strings.Repeat(" ", depth*2))
+	c.tree.WriteString(name)
+}
+
 // Tree walks the descendents of this Account
 // and returns a string of its structure in tree form
 func Tree(a journal.Account, prepender func(a journal.Account) string, shouldCollapseOnlyChildren bool) string {
@@ -35,38 +47,23 @@ func Tree(a journal.Account, prepender func(a journal.Account) string, shouldCol
 		c = tree(*a.Children[childName], c)
 	}
 
-	return c.tree
+	return c.tree.String()
 }
 
 // We keep track of the current line for collapsed only children
 // TODO: return two strings (tree, collapsedAccountsLine)
 func tree(a journal.Account, c treeContext) treeContext {
-	calculateSpaces := func(depth int) string {
-		spaces := ""
-		var tabWidth int = 2
-		for i := 0; i < depth*tabWidth; i++ {
-			spaces = fmt.Sprintf(" %s", spaces)
-		}
-		return spaces
-	}
-
 	// Only children are a special case because they are collapsed to a single line
 	// For this to work with the prepender, we keep track of it separately from the tree
 	// until we reach a leaf, where we can rejoin the tree
 	if c.isOnlyChild && c.shouldCollapseOnlyChildren {
 		if len(a.Children) == 1 {
-			c.collapsedAccounts = fmt.Sprintf("%s:%s", c.collapsedAccounts, a.Name)
+			c.collapsedAccounts = c.collapsedAccounts + ":" + a.Name
 			c.isOnlyChild = true
 		} else {
 			// If this only child has 0 or >1 children
 			// we need to add the line to the tree + the prepended string
-			spaces := calculateSpaces(c.collapsedAccountsDepth)
-			if len(c.tree) == 0 {
-				// If this is the first account, don't add a newline
-				c.tree = fmt.Sprintf("%s%s%s:%s", c.prepender(a), spaces, c.collapsedAccounts, a.Name)
-			} else {
-				c.tree = fmt.Sprintf("%s\n%s%s%s:%s", c.tree, c.prepender(a), spaces, c.collapsedAccounts, a.Name)
-			}
+			c.writeLine(a, c.collapsedAccountsDepth, c.collapsedAccounts+":"+a.Name)
 			c.collapsedAccounts = ""
 			c.isOnlyChild = false
 		}
@@ -81,19 +78,14 @@ func tree(a journal.Account, c treeContext) treeContext {
 			// If we have one child, we should add ourselves to the collapsedAccounts line
 			if len(c.collapsedAccounts) == 0 {
 				// If we're the first only child, don't add a colon
-				c.collapsedAccounts = fmt.Sprintf("%s%s", calculateSpaces(c.depth), a.Name)
+				c.collapsedAccounts = strings.Repeat(" ", c.depth*2) + a.Name
 			} else {
-				c.collapsedAccounts = fmt.Sprintf("%s:%s", c.collapsedAccounts, a.Name)
+				c.collapsedAccounts = c.collapsedAccounts + ":" + a.Name
 			}
 			c.isOnlyChild = true // let the Account's child know it has no siblings
 		} else {
 			// If we have 0 or >1 children, we should add ourselves to the tree
-			if len(c.tree) == 0 {
-				// If this is the first account, don't add a newline
-				c.tree = fmt.Sprintf("%s%s%s", c.prepender(a), calculateSpaces(c.depth), a.Name)
-			} else {
-				c.tree = fmt.Sprintf("%s\n%s%s%s", c.tree, c.prepender(a), calculateSpaces(c.depth), a.Name)
-			}
+			c.writeLine(a, c.depth, a.Name)
 			c.isOnlyChild = false // let the Account's children know they have siblings
 		}
 
@@ -19,7 +19,7 @@ func TestTree(t *testing.T) {
 £123  I0:I1`
 	prepender := func(a journal.Account) string { return p }
 	root, _, _ := createRoot()
-	got := root.Tree(prepender)
+	got := Tree(*root, prepender, true)
 
 	if got != expected {
 		t.Fatalf("\nExpected:\n%s\nGot:\n%s", expected, got)
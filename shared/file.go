@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomically replaces the file at path with what write writes, giving it the permissions in mode.
+// It is written to a temporary file next to path which only replaces the file once writing has succeeded.
+func WriteFileAtomically(path string, mode os.FileMode, write func(w io.Writer) error) error {
+	dir, name := filepath.Split(path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	file, err := ioutil.TempFile(dir, "."+name+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Tidy up the temporary file if anything goes wrong
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if err := write(file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	succeeded = true
+	return nil
+}
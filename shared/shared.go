@@ -1,4 +1,4 @@
-// Package shared exposes constants needed by multiple packages
+// Package shared exposes constants and helpers needed by multiple packages
 package shared
 
 const TabWidth = 2 // size of a tab in spaces